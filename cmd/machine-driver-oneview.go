@@ -17,10 +17,137 @@ limitations under the License.
 package main
 
 import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/Sheetal-R/docker-machine-oneview/oneview"
 	"github.com/docker/machine/libmachine/drivers/plugin"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	heartbeatTimeout = 10 * time.Second
+	// cleanupTimeout - how long an interrupted operation may take to clean up
+	cleanupTimeout = 10 * time.Minute
 )
 
 func main() {
-	plugin.RegisterDriver(oneview.NewDriver("", ""))
+	if len(os.Args) > 1 && os.Args[1] == "rotate-ssh-key" {
+		if err := rotateSSHKey(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tunnel" {
+		if err := tunnel(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if os.Getenv(localbinary.PluginEnvKey) != localbinary.PluginEnvVal {
+		// prints the usage message for plugin binaries and exits
+		plugin.RegisterDriver(oneview.NewDriver("", ""))
+	}
+	registerDriver(oneview.NewDriver("", "").(*oneview.Driver))
+}
+
+// registerDriver - serve the driver like plugin.RegisterDriver, but let an
+// interrupted create, stop or remove clean up the appliances before exiting
+func registerDriver(d *oneview.Driver) {
+	log.SetDebug(true)
+	os.Setenv("MACHINE_DEBUG", "1")
+
+	rpcd := rpcdriver.NewRPCServerDriver(d)
+	rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd)
+	rpc.RegisterName(rpcdriver.RPCServiceNameV1, rpcd)
+	rpc.HandleHTTP()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading RPC server: %s\n", err)
+		os.Exit(1)
+	}
+	defer listener.Close()
+
+	fmt.Println(listener.Addr())
+
+	go http.Serve(listener, nil)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-rpcd.CloseCh:
+			log.Debug("Closing plugin on server side")
+			os.Exit(0)
+		case <-rpcd.HeartbeatCh:
+			continue
+		case <-signals:
+			d.Interrupt()
+			exitAfterCleanup(d, 130)
+		case <-time.After(heartbeatTimeout):
+			// docker-machine went away, stop what is running and clean up
+			d.Interrupt()
+			exitAfterCleanup(d, 1)
+		}
+	}
+}
+
+// exitAfterCleanup - wait for running operations to clean up, then exit
+func exitAfterCleanup(d *oneview.Driver, code int) {
+	if !d.WaitOperations(cleanupTimeout) {
+		log.Warnf("Cleanup did not finish in %s, check OneView and ICSP for leftover resources", cleanupTimeout)
+	}
+	os.Exit(code)
+}
+
+// rotateSSHKey - rotate-ssh-key [--storage-path path] machine
+func rotateSSHKey(args []string) error {
+	flags := flag.NewFlagSet("rotate-ssh-key", flag.ContinueOnError)
+	storePath := flags.String("storage-path", oneview.DefaultStorePath(), "docker-machine storage path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s rotate-ssh-key [--storage-path path] machine", os.Args[0])
+	}
+
+	d, err := oneview.LoadDriver(*storePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := d.RotateSSHKey(); err != nil {
+		return err
+	}
+	return oneview.SaveDriver(d)
+}
+
+// tunnel - tunnel [--storage-path path] machine
+func tunnel(args []string) error {
+	flags := flag.NewFlagSet("tunnel", flag.ContinueOnError)
+	storePath := flags.String("storage-path", oneview.DefaultStorePath(), "docker-machine storage path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s tunnel [--storage-path path] machine", os.Args[0])
+	}
+
+	d, err := oneview.LoadDriver(*storePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return d.ServeDockerTunnel()
 }
@@ -0,0 +1,246 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ErrDriverAPIProxyScheme - net/http only speaks to http proxies
+var ErrDriverAPIProxyScheme = errors.New("Option --oneview-api-proxy must be an http proxy, http://host:port")
+
+// apiProxySecret - name of the proxy password in the machine secrets
+const apiProxySecret = "apiProxyPassword"
+
+// variables net/http reads the proxy and its exceptions from
+var (
+	proxyEnvNames   = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"}
+	noProxyEnvNames = []string{"NO_PROXY", "no_proxy"}
+)
+
+// proxyEnvironment - proxy settings read from the environment
+type proxyEnvironment struct {
+	HTTP    string
+	HTTPS   string
+	NoProxy []string
+}
+
+// environmentProxy - the proxy settings of the environment the plugin was
+// started with.  The ILO and dhcp lease api requests keep them once
+// applyAPIProxy handed --oneview-api-proxy to the library clients.
+var environmentProxy = readProxyEnvironment()
+
+// getenvAnyCase - the first of the variables names that is set
+func getenvAnyCase(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// readProxyEnvironment - the proxy settings of the environment
+func readProxyEnvironment() proxyEnvironment {
+	return proxyEnvironment{
+		HTTP:    getenvAnyCase("HTTP_PROXY", "http_proxy"),
+		HTTPS:   getenvAnyCase("HTTPS_PROXY", "https_proxy"),
+		NoProxy: parseNoProxy(getenvAnyCase(noProxyEnvNames...)),
+	}
+}
+
+// proxy - the proxy func of these settings, for http.Transport
+func (e proxyEnvironment) proxy(req *http.Request) (*url.URL, error) {
+	proxy := e.HTTP
+	if req.URL.Scheme == "https" {
+		proxy = e.HTTPS
+	}
+	if proxy == "" || bypassesProxy(req.URL.String(), e.NoProxy) {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	return url.Parse(proxy)
+}
+
+// parseAPIProxy - url of --oneview-api-proxy, http:// when no scheme is given
+func parseAPIProxy(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid option --oneview-api-proxy : %s", err)
+	}
+	if u.Scheme != "http" {
+		return nil, ErrDriverAPIProxyScheme
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Invalid option --oneview-api-proxy, no host in %s", value)
+	}
+	return u, nil
+}
+
+// parseNoProxy - hosts and domains of --oneview-api-no-proxy
+func parseNoProxy(value string) []string {
+	var hosts []string
+	for _, h := range strings.Split(value, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// setAPIProxyConfig - apply the --oneview-api-proxy options, credentials
+// given apart override those of the url.  The password is kept out of
+// APIProxy, create saves it with the machine secrets.
+func (d *Driver) setAPIProxyConfig(proxy string, user string, password string, noProxy string) error {
+	d.APIProxy, d.proxyPassword, d.APINoProxy = "", "", parseNoProxy(noProxy)
+	if proxy == "" {
+		return nil
+	}
+	u, err := parseAPIProxy(proxy)
+	if err != nil {
+		return err
+	}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	if u.User != nil {
+		d.proxyPassword, _ = u.User.Password()
+		u.User = url.User(u.User.Username())
+	}
+	d.APIProxy = u.String()
+	return nil
+}
+
+// saveAPIProxySecret - keep the proxy password in the machine secrets
+func (d *Driver) saveAPIProxySecret() error {
+	if d.proxyPassword == "" {
+		return nil
+	}
+	return d.saveSecret(apiProxySecret, d.proxyPassword)
+}
+
+// apiProxyURL - the proxy with its credentials, nil when there is none
+func (d *Driver) apiProxyURL() *url.URL {
+	if d.APIProxy == "" {
+		return nil
+	}
+	u, err := url.Parse(d.APIProxy)
+	if err != nil {
+		return nil
+	}
+	if u.User == nil {
+		return u
+	}
+	if _, ok := u.User.Password(); ok {
+		// machines created before the password moved to the secrets
+		return u
+	}
+	password := d.proxyPassword
+	if password == "" {
+		if password, err = d.getSecret(apiProxySecret); err != nil {
+			log.Warnf("Unable to read the --oneview-api-proxy password : %s", err)
+		}
+	}
+	if password != "" {
+		u.User = url.UserPassword(u.User.Username(), password)
+	}
+	return u
+}
+
+// redactedProxy - the proxy for logs, the password hidden
+func redactedProxy(u *url.URL) string {
+	r := *u
+	if r.User != nil {
+		r.User = url.User(r.User.Username())
+	}
+	return r.String()
+}
+
+// bypassesProxy - true when the host of endpoint is in noProxy, matched the
+// way net/http matches NO_PROXY : * for every host, a host name, or a
+// domain with or without its leading dot, ports ignored
+func bypassesProxy(endpoint string, noProxy []string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	for _, p := range noProxy {
+		if p == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(p); err == nil {
+			p = h
+		}
+		p = strings.Trim(p, "[]")
+		switch {
+		case host == p, strings.HasPrefix(p, ".") && (strings.HasSuffix(host, p) || host == p[1:]),
+			!strings.HasPrefix(p, ".") && strings.HasSuffix(host, "."+p):
+			return true
+		}
+	}
+	return false
+}
+
+// apiProxy - the proxy func of the http client of the appliance at endpoint.
+// --oneview-api-proxy only applies to the OneView and ICSP clients, without
+// it the proxy comes from the environment.
+func (d *Driver) apiProxy(endpoint string) func(*http.Request) (*url.URL, error) {
+	proxy := d.apiProxyURL()
+	if proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+	if bypassesProxy(endpoint, d.APINoProxy) {
+		log.Debugf("%s is reached directly, it is in --oneview-api-no-proxy", endpoint)
+		return nil
+	}
+	log.Debugf("%s is reached through proxy %s", endpoint, redactedProxy(proxy))
+	return http.ProxyURL(proxy)
+}
+
+// applyAPIProxy - hand --oneview-api-proxy to the OneView and ICSP library
+// clients.  The library builds the http transport of each request with the
+// proxy of the environment, so the proxy variables of the plugin process are
+// set before the first library call, net/http reads them once.  The driver
+// own requests use apiProxy and the ILO and dhcp lease api requests the
+// environment the plugin started with.
+func (d *Driver) applyAPIProxy() {
+	proxy := d.apiProxyURL()
+	if proxy == nil {
+		return
+	}
+	d.proxyOnce.Do(func() {
+		for _, name := range proxyEnvNames {
+			os.Setenv(name, proxy.String())
+		}
+		for _, name := range noProxyEnvNames {
+			os.Setenv(name, strings.Join(d.APINoProxy, ","))
+		}
+		for _, c := range []*rest.Client{&d.ClientOV.Client, &d.ClientICSP.Client} {
+			if bypassesProxy(c.Endpoint, d.APINoProxy) {
+				log.Debugf("library calls reach %s directly, it is in --oneview-api-no-proxy", c.Endpoint)
+			} else {
+				log.Debugf("library calls reach %s through proxy %s", c.Endpoint, redactedProxy(proxy))
+			}
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package oneview
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// auditFile - per machine JSON lines record of the appliance requests
+	auditFile = "audit.jsonl"
+
+	// auditKeep - suffix of the previous audit file kept after a rotation
+	auditKeep = ".1"
+
+	applianceOV   = "oneview"
+	applianceICSP = "icsp"
+)
+
+// auditEntry - one line of the audit file, an appliance request or the start
+// and end of a driver operation
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	ID         string    `json:"id"`
+	Machine    string    `json:"machine"`
+	Operation  string    `json:"operation,omitempty"`
+	Event      string    `json:"event"`
+	Appliance  string    `json:"appliance,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	TaskURI    string    `json:"taskUri,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// operation - the driver operation in progress, its id correlates the log
+// lines and audit entries it produces
+type operation struct {
+	sync.Mutex
+	id    string
+	name  string
+	depth int
+}
+
+// auditMaxSize - size past which the audit file is rotated, a variable so
+// tests can rotate small files
+var auditMaxSize int64 = 10 << 20
+
+// auditLock - serializes writes to the audit files of this process
+var auditLock sync.Mutex
+
+// secretFields - json fields whose values are never written to the audit file
+var secretFields = regexp.MustCompile(`(?i)("[a-z_]*(password|secret|token|sessionid|privatekey|private_key)[a-z_]*"\s*:\s*)"(\\.|[^"\\])*"`)
+
+// redactBody - json text with the values of credential fields replaced
+func redactBody(body string) string {
+	return secretFields.ReplaceAllString(body, `$1"`+redacted+`"`)
+}
+
+// auditBody - redacted json of a request body, empty without a body
+func auditBody(body interface{}) string {
+	if body == nil {
+		return ""
+	}
+	data, err := json.Marshal(body)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return redactBody(string(data))
+}
+
+// taskURI - uri of the OneView task or ICSP job an asynchronous call
+// answered with
+func taskURI(data []byte) string {
+	var resp struct {
+		URI     string `json:"uri"`
+		TaskURI string `json:"taskUri"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return ""
+	}
+	if resp.TaskURI != "" {
+		return resp.TaskURI
+	}
+	if strings.HasPrefix(resp.URI, "/rest/tasks/") || strings.HasPrefix(resp.URI, "/rest/os-deployment-jobs/") {
+		return resp.URI
+	}
+	return ""
+}
+
+// newCorrelationID - random id for an operation
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// auditOperation - give the operation a correlation id unless it runs inside
+// another one, call the returned func when done, err points to its result
+func (d *Driver) auditOperation(name string, err *error) func() {
+	d.operation.Lock()
+	d.operation.depth++
+	if d.operation.depth > 1 {
+		d.operation.Unlock()
+		return func() {
+			d.operation.Lock()
+			d.operation.depth--
+			d.operation.Unlock()
+		}
+	}
+	d.operation.id = newCorrelationID()
+	d.operation.name = name
+	id := d.operation.id
+	d.operation.Unlock()
+
+	start := time.Now()
+	log.Debugf("%s %s started, correlation id %s", name, d.MachineName, id)
+	d.audit(auditEntry{Event: "begin"})
+	return func() {
+		e := auditEntry{Event: "end", DurationMs: msSince(start)}
+		if err != nil && *err != nil {
+			e.Error = redactBody((*err).Error())
+		}
+		d.audit(e)
+		log.Debugf("%s %s done, correlation id %s", name, d.MachineName, id)
+
+		d.operation.Lock()
+		d.operation.depth--
+		d.operation.id = ""
+		d.operation.name = ""
+		d.operation.Unlock()
+	}
+}
+
+// msSince - milliseconds elapsed since start
+func msSince(start time.Time) int64 {
+	return int64(time.Since(start) / time.Millisecond)
+}
+
+// auditRequest - record an appliance request made for the current operation
+func (d *Driver) auditRequest(e auditEntry, start time.Time, err error) {
+	e.Time = start.UTC()
+	e.Event = "request"
+	e.DurationMs = msSince(start)
+	if err != nil {
+		if e.Status == 0 {
+			e.Status = errorStatusCode(err)
+		}
+		e.Error = redactBody(err.Error())
+	}
+	d.audit(e)
+}
+
+// audit - append an entry to the machine audit file, best effort
+func (d *Driver) audit(e auditEntry) {
+	if d.BaseDriver == nil || d.StorePath == "" {
+		return
+	}
+	d.operation.Lock()
+	e.ID = d.operation.id
+	e.Operation = d.operation.name
+	d.operation.Unlock()
+	e.Machine = d.MachineName
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	path := d.ResolveStorePath(auditFile)
+	rotateAudit(path, int64(len(data)+1))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Debugf("unable to write audit entry : %s", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// rotateAudit - move the audit file at path to path.1 when adding size bytes
+// would take it past auditMaxSize, only the previous file is kept
+func rotateAudit(path string, size int64) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size()+size <= auditMaxSize {
+		return
+	}
+	// windows does not rename over an existing file
+	if err := os.Remove(path + auditKeep); err != nil && !os.IsNotExist(err) {
+		log.Debugf("unable to remove the previous audit file : %s", err)
+	}
+	if err := os.Rename(path, path+auditKeep); err != nil {
+		log.Debugf("unable to rotate the audit file : %s", err)
+	}
+}
@@ -0,0 +1,159 @@
+package oneview
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+// readAudit - entries of the machine audit file
+func readAudit(t *testing.T, d *Driver) (entries []auditEntry) {
+	f, err := os.Open(d.ResolveStorePath(auditFile))
+	assert.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// TestAuditOperation - each http request is recorded with its status and
+// the id of the outermost operation
+func TestAuditOperation(t *testing.T) {
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/server-hardware/1/powerState":
+			w.Header().Set("Location", "/rest/tasks/1")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errorCode": "INVALID_POWER_STATE"}`)
+		}
+	})
+	defer done()
+
+	remove := func() (err error) {
+		defer d.auditOperation("remove", &err)()
+		stop := func() (err error) {
+			defer d.auditOperation("stop", &err)()
+			body := map[string]string{"powerState": "Off", "iloPassword": "secret"}
+			if err := d.ovCall(rest.PUT, "/rest/server-hardware/1/powerState", body, nil); err != nil {
+				return err
+			}
+			return d.ovCall(rest.PUT, "/rest/server-hardware/2/powerState", body, nil)
+		}
+		return stop()
+	}
+	assert.Error(t, remove())
+
+	entries := readAudit(t, d)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, "begin", entries[0].Event)
+	assert.Equal(t, "request", entries[1].Event)
+	assert.Equal(t, "end", entries[4].Event)
+	for _, e := range entries {
+		assert.Equal(t, entries[0].ID, e.ID)
+		assert.Equal(t, "remove", e.Operation)
+		assert.Equal(t, "test", e.Machine)
+	}
+	assert.NotEmpty(t, entries[0].ID)
+
+	// the login is recorded without the password
+	assert.Equal(t, "POST", entries[1].Method)
+	assert.Equal(t, loginSessionsURI, entries[1].Path)
+	assert.Equal(t, 200, entries[1].Status)
+	assert.NotContains(t, entries[1].Body, "secret")
+
+	assert.Equal(t, "PUT", entries[2].Method)
+	assert.Equal(t, "/rest/server-hardware/1/powerState", entries[2].Path)
+	assert.Equal(t, 202, entries[2].Status)
+	assert.Equal(t, "/rest/tasks/1", entries[2].TaskURI)
+	assert.Empty(t, entries[2].Error)
+	assert.NotContains(t, entries[2].Body, "secret")
+
+	assert.Equal(t, 400, entries[3].Status)
+	assert.Contains(t, entries[3].Error, "INVALID_POWER_STATE")
+	assert.Contains(t, entries[4].Error, "400")
+
+	// a new operation gets a new id
+	var ok error
+	d.auditOperation("start", &ok)()
+	entries = readAudit(t, d)
+	assert.NotEqual(t, entries[0].ID, entries[5].ID)
+}
+
+// TestAuditLibraryCall - a library call is one entry with its error
+func TestAuditLibraryCall(t *testing.T) {
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer done()
+
+	call := func() (err error) {
+		defer d.auditOperation("remove", &err)()
+		return d.libraryCall(applianceICSP, "DELETE", "/rest/os-deployment-servers/7", func() error {
+			return errors.New("Error in response: server in use\n Response Status: 409 Conflict")
+		})()
+	}
+	assert.Error(t, call())
+
+	entries := readAudit(t, d)
+	assert.Len(t, entries, 3)
+	e := entries[1]
+	assert.Equal(t, "request", e.Event)
+	assert.Equal(t, "remove", e.Operation)
+	assert.Equal(t, applianceICSP, e.Appliance)
+	assert.Equal(t, "DELETE /rest/os-deployment-servers/7", e.Method+" "+e.Path)
+	assert.Equal(t, http.StatusConflict, e.Status)
+	assert.Contains(t, e.Error, "server in use")
+}
+
+// TestAuditRotation - a full audit file is moved aside, only one previous
+// file is kept
+func TestAuditRotation(t *testing.T) {
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer done()
+	defer func(size int64) { auditMaxSize = size }(auditMaxSize)
+	auditMaxSize = 300
+
+	for i := 0; i < 10; i++ {
+		d.audit(auditEntry{Event: "request", Path: fmt.Sprintf("/rest/server-hardware/%d", i)})
+	}
+	path := d.ResolveStorePath(auditFile)
+	for _, f := range []string{path, path + auditKeep} {
+		info, err := os.Stat(f)
+		if assert.NoError(t, err) {
+			assert.True(t, info.Size() <= auditMaxSize, f)
+		}
+	}
+	_, err := os.Stat(path + auditKeep + auditKeep)
+	assert.True(t, os.IsNotExist(err))
+
+	entries := readAudit(t, d)
+	assert.NotEmpty(t, entries)
+	assert.Equal(t, "/rest/server-hardware/9", entries[len(entries)-1].Path)
+}
+
+// TestRedactBody - credential values never reach the audit file
+func TestRedactBody(t *testing.T) {
+	body := `{"userName":"admin","password":"p\"w","loginMsgAck":"true","sessionID":"abc","customAttributes":[{"key":"public_key","value":"ssh-rsa"}]}`
+	redactedBody := redactBody(body)
+	assert.NotContains(t, redactedBody, `p\"w`)
+	assert.NotContains(t, redactedBody, "abc")
+	assert.Contains(t, redactedBody, `"userName":"admin"`)
+	assert.Contains(t, redactedBody, `"ssh-rsa"`)
+
+	assert.Equal(t, "/rest/tasks/5", taskURI([]byte(`{"uri":"/rest/tasks/5","taskState":"Running"}`)))
+	assert.Equal(t, "/rest/tasks/6", taskURI([]byte(`{"uri":"/rest/server-profiles/1","taskUri":"/rest/tasks/6"}`)))
+	assert.Equal(t, "", taskURI([]byte(`{"uri":"/rest/server-profiles/1"}`)))
+	assert.Equal(t, "/rest/os-deployment-jobs/7", taskURI([]byte(`{"uri":"/rest/os-deployment-jobs/7","running":"true"}`)))
+	assert.Equal(t, "", auditBody(nil))
+}
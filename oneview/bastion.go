@@ -0,0 +1,476 @@
+package oneview
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultBastionPort = 22
+	bastionTimeout     = 30 * time.Second
+	// tunnelHost - local end of the tunnels, the docker url uses localhost
+	// as the engine certificate is valid for it
+	tunnelHost = "127.0.0.1"
+)
+
+// Error messages
+var (
+	ErrDriverBastionFormat = errors.New("Invalid option --oneview-ssh-bastion, use user@host or user@host:port")
+	ErrDriverBastionAgent  = errors.New("Option --oneview-ssh-bastion-key requests an ssh-agent identity but SSH_AUTH_SOCK is not set")
+	ErrDriverNoBastion     = errors.New("Machine was not created with --oneview-ssh-bastion, connect to its docker url directly")
+	ErrDriverBastionPin    = errors.New("Invalid option --oneview-ssh-bastion-host-key, use the SHA256: fingerprint printed by ssh-keygen -lf")
+	ErrDriverNoTunnelPort  = errors.New("Machine behind --oneview-ssh-bastion has no local ssh port yet, run the tunnel command of the driver once to give it one")
+)
+
+// bastionTunnels - ssh connection to the bastion and the local ports
+// forwarded through it, shared by every connection of the process
+type bastionTunnels struct {
+	sync.Mutex
+	client *gossh.Client
+	ports  map[string]int
+}
+
+// parseBastion - user and host:port of --oneview-ssh-bastion
+func parseBastion(value string) (string, string, error) {
+	i := strings.LastIndex(value, "@")
+	if i <= 0 || i == len(value)-1 {
+		return "", "", ErrDriverBastionFormat
+	}
+	user, address := value[:i], value[i+1:]
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = strings.Trim(address, "[]"), strconv.Itoa(defaultBastionPort)
+	}
+	if p, err := strconv.Atoi(port); host == "" || err != nil || p < 1 || p > 65535 {
+		return "", "", ErrDriverBastionFormat
+	}
+	return user, net.JoinHostPort(host, port), nil
+}
+
+// setBastionConfig - apply the --oneview-ssh-bastion, --oneview-ssh-bastion-key
+// and --oneview-ssh-bastion-host-key options, the key is a private key file or
+// an ssh-agent identity
+func (d *Driver) setBastionConfig(bastion string, key string, hostKey string) error {
+	d.SSHBastion, d.SSHBastionKey, d.SSHBastionHostKey = "", "", ""
+	if bastion == "" {
+		return nil
+	}
+	user, address, err := parseBastion(bastion)
+	if err != nil {
+		return err
+	}
+	d.SSHBastion = user + "@" + address
+	if hostKey != "" && !strings.HasPrefix(hostKey, "SHA256:") {
+		return ErrDriverBastionPin
+	}
+	d.SSHBastionHostKey = hostKey
+	if key == "" || key == sshKeyAgent || strings.HasPrefix(key, sshKeyAgent+":") {
+		d.SSHBastionKey = key
+		return nil
+	}
+	if d.SSHBastionKey, err = filepath.Abs(key); err != nil {
+		return err
+	}
+	if _, err := os.Stat(d.SSHBastionKey); err != nil {
+		return fmt.Errorf("Unable to use ssh key from --oneview-ssh-bastion-key, %s", err)
+	}
+	return nil
+}
+
+// bastionSigner - the identity logging in to the bastion, ssh-agent when no
+// key file is given
+func (d *Driver) bastionSigner() (gossh.Signer, error) {
+	if d.SSHBastionKey != "" && d.SSHBastionKey != sshKeyAgent && !strings.HasPrefix(d.SSHBastionKey, sshKeyAgent+":") {
+		data, err := ioutil.ReadFile(d.SSHBastionKey)
+		if err != nil {
+			return nil, err
+		}
+		return gossh.ParsePrivateKey(data)
+	}
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return nil, ErrDriverBastionAgent
+	}
+	return agentSigner(strings.TrimPrefix(strings.TrimPrefix(d.SSHBastionKey, sshKeyAgent), ":"))
+}
+
+// bastionClient - the connection to the bastion, opened on first use, the
+// caller holds the tunnels lock
+func (d *Driver) bastionClient() (*gossh.Client, error) {
+	if d.bastion.client != nil {
+		return d.bastion.client, nil
+	}
+	i := strings.LastIndex(d.SSHBastion, "@")
+	user, address := d.SSHBastion[:i], d.SSHBastion[i+1:]
+	signer, err := d.bastionSigner()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", address, bastionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to ssh bastion %s : %s", d.SSHBastion, err)
+	}
+	c, chans, reqs, err := gossh.NewClientConn(conn, address, &gossh.ClientConfig{
+		User:            user,
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signer)},
+		HostKeyCallback: d.checkBastionHostKey,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Unable to login to ssh bastion %s : %s", d.SSHBastion, err)
+	}
+	log.Debugf("connected to ssh bastion %s", d.SSHBastion)
+	d.bastion.client = gossh.NewClient(c, chans, reqs)
+	return d.bastion.client, nil
+}
+
+// checkBastionHostKey - accept the bastion when its key has the fingerprint of
+// --oneview-ssh-bastion-host-key, or else is the key known_hosts has for it
+func (d *Driver) checkBastionHostKey(address string, remote net.Addr, key gossh.PublicKey) error {
+	if d.SSHBastionHostKey == "" {
+		return checkKnownHost(filepath.Join(mcnutils.GetHomeDir(), ".ssh", "known_hosts"), address, key)
+	}
+	if fingerprint := sshKeyFingerprint(key); fingerprint != d.SSHBastionHostKey {
+		return fmt.Errorf("Host key %s of ssh bastion %s does not match --oneview-ssh-bastion-host-key %s", fingerprint, address, d.SSHBastionHostKey)
+	}
+	return nil
+}
+
+// checkKnownHost - accept key when the known_hosts file at path has it for
+// address, host names written plain or hashed as ssh does with HashKnownHosts
+func checkKnownHost(path string, address string, key gossh.PublicKey) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	names := []string{"[" + host + "]:" + port}
+	if port == strconv.Itoa(defaultBastionPort) {
+		names = append(names, host)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	known := false
+	for len(data) > 0 {
+		marker, hosts, k, _, rest, err := gossh.ParseKnownHosts(data)
+		if err != nil {
+			break
+		}
+		data = rest
+		if marker == "cert-authority" || !knownHostMatches(hosts, names) {
+			continue
+		}
+		same := string(k.Marshal()) == string(key.Marshal())
+		if marker == "revoked" && same {
+			return fmt.Errorf("Host key %s of ssh bastion %s is revoked in %s", sshKeyFingerprint(key), address, path)
+		}
+		known = known || same
+		if !same && marker == "" {
+			log.Debugf("%s has another %s key for %s", path, k.Type(), address)
+		}
+	}
+	if !known {
+		return fmt.Errorf("Host key %s of ssh bastion %s is not in %s, add it with ssh-keyscan or pin it with --oneview-ssh-bastion-host-key", sshKeyFingerprint(key), address, path)
+	}
+	return nil
+}
+
+// knownHostMatches - true when one of the known_hosts patterns is one of names
+func knownHostMatches(patterns []string, names []string) bool {
+	for _, p := range patterns {
+		for _, n := range names {
+			if p == n || hashedHostMatches(p, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hashedHostMatches - true when pattern is |1|salt|hash of name
+func hashedHostMatches(pattern string, name string) bool {
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 || parts[0] != "" || parts[1] != "1" {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)) == parts[3]
+}
+
+// connectBastion - make sure the bastion can be logged in to
+func (d *Driver) connectBastion() error {
+	d.bastion.Lock()
+	defer d.bastion.Unlock()
+	_, err := d.bastionClient()
+	return err
+}
+
+// bastionDial - connect to address through the bastion, logging in again
+// once when the bastion connection was lost
+func (d *Driver) bastionDial(address string) (net.Conn, error) {
+	d.bastion.Lock()
+	defer d.bastion.Unlock()
+	for attempt := 0; ; attempt++ {
+		client, err := d.bastionClient()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := client.Dial("tcp", address)
+		if err == nil || attempt > 0 {
+			return conn, err
+		}
+		log.Debugf("reconnecting to ssh bastion %s : %s", d.SSHBastion, err)
+		client.Close()
+		d.bastion.client = nil
+	}
+}
+
+// bastionForward - local port forwarded to address through the bastion,
+// listening on local the first time address is asked for
+func (d *Driver) bastionForward(local string, address string) (int, error) {
+	d.bastion.Lock()
+	defer d.bastion.Unlock()
+	if port, ok := d.bastion.ports[address]; ok {
+		return port, nil
+	}
+	l, err := net.Listen("tcp", local)
+	if err != nil {
+		return 0, err
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	if d.bastion.ports == nil {
+		d.bastion.ports = make(map[string]int)
+	}
+	d.bastion.ports[address] = port
+	log.Debugf("forwarding %s to %s through ssh bastion %s", l.Addr(), address, d.SSHBastion)
+	go d.serveForward(l, address)
+	return port, nil
+}
+
+// serveForward - pass the connections of l to address until l is closed
+func (d *Driver) serveForward(l net.Listener, address string) error {
+	for {
+		local, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			remote, err := d.bastionDial(address)
+			if err != nil {
+				log.Warnf("Unable to reach %s through ssh bastion %s : %s", address, d.SSHBastion, err)
+				local.Close()
+				return
+			}
+			pipe(local, remote)
+		}()
+	}
+}
+
+// pipe - copy both ways until either side is done
+func pipe(a net.Conn, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copyTo := func(dst net.Conn, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyTo(a, b)
+	go copyTo(b, a)
+	<-done
+	a.Close()
+	b.Close()
+}
+
+// sshEndpoint - host and port the driver's own ssh connects to, a local
+// tunnel when the machine is behind a bastion
+func (d *Driver) sshEndpoint(ip string) (string, int, error) {
+	if d.SSHBastion == "" {
+		return sshHost(ip), d.SSHPort, nil
+	}
+	if err := d.connectBastion(); err != nil {
+		return "", 0, err
+	}
+	port, err := d.bastionForward(net.JoinHostPort(tunnelHost, "0"), hostPort(ip, d.SSHPort))
+	return tunnelHost, port, err
+}
+
+// GetSSHPort - port docker-machine connects to, the local port saved at
+// create for the ssh tunnel when the machine is behind a bastion.  The port
+// is forwarded by create and by the tunnel command.
+func (d *Driver) GetSSHPort() (int, error) {
+	if d.SSHBastion == "" {
+		return d.BaseDriver.GetSSHPort()
+	}
+	if d.TunnelSSHPort == 0 {
+		return 0, ErrDriverNoTunnelPort
+	}
+	return d.TunnelSSHPort, nil
+}
+
+// bastionURL - the docker url of a machine behind a bastion, the engine port
+// on localhost, forwarded by create and by the tunnel command
+func (d *Driver) bastionURL() string {
+	return fmt.Sprintf("tcp://localhost:%d", d.enginePort())
+}
+
+// tunnelPortsInUse - local ports saved by the other machines of the store
+// behind a bastion
+func (d *Driver) tunnelPortsInUse() map[int]bool {
+	used := make(map[int]bool)
+	configs, _ := filepath.Glob(machineConfigPath(d.StorePath, "*"))
+	for _, path := range configs {
+		if path == machineConfigPath(d.StorePath, d.MachineName) {
+			continue
+		}
+		var config struct {
+			Driver struct {
+				SSHBastion    string
+				EnginePort    int
+				TunnelSSHPort int
+			}
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &config) != nil || config.Driver.SSHBastion == "" {
+			continue
+		}
+		used[config.Driver.EnginePort] = true
+		used[config.Driver.TunnelSSHPort] = true
+	}
+	return used
+}
+
+// freeTunnelPort - a local port nothing listens on and no other machine of
+// the store has saved
+func freeTunnelPort(used map[int]bool) (int, error) {
+	for attempt := 0; attempt < 100; attempt++ {
+		l, err := net.Listen("tcp", net.JoinHostPort(tunnelHost, "0"))
+		if err != nil {
+			return 0, err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		if !used[port] {
+			used[port] = true
+			return port, nil
+		}
+	}
+	return 0, errors.New("Unable to find a free local port for the ssh bastion tunnel")
+}
+
+// allocateTunnelPorts - pick the local ports of a machine behind a bastion,
+// saved with the machine.  docker-machine configures the engine with the
+// port of the docker url, so unless --oneview-engine-port is given the
+// engine port is the local port picked for the machine.
+func (d *Driver) allocateTunnelPorts() error {
+	if d.SSHBastion == "" {
+		return nil
+	}
+	used := d.tunnelPortsInUse()
+	if d.EnginePort == 0 || d.EnginePort == engine.DefaultPort {
+		port, err := freeTunnelPort(used)
+		if err != nil {
+			return err
+		}
+		d.EnginePort = port
+	} else if used[d.EnginePort] {
+		return fmt.Errorf("Port %d from --oneview-engine-port is the tunnel port of another machine behind a bastion", d.EnginePort)
+	}
+	used[d.EnginePort] = true
+	if d.TunnelSSHPort == 0 {
+		port, err := freeTunnelPort(used)
+		if err != nil {
+			return err
+		}
+		d.TunnelSSHPort = port
+	}
+	log.Debugf("local ports of %s behind ssh bastion %s : docker %d, ssh %d", d.MachineName, d.SSHBastion, d.EnginePort, d.TunnelSSHPort)
+	return nil
+}
+
+// startTunnels - forward the saved local ssh and docker ports of the machine
+// through the bastion until the process ends, create starts them before the
+// key push so docker-machine can provision the machine.  The error of a
+// forward that stops is sent on the returned channel.
+func (d *Driver) startTunnels(ip string) ([]net.Listener, <-chan error, error) {
+	if err := d.connectBastion(); err != nil {
+		return nil, nil, err
+	}
+	forwards := []struct {
+		local  int
+		remote string
+	}{
+		{local: d.TunnelSSHPort, remote: hostPort(ip, d.SSHPort)},
+		{local: d.enginePort(), remote: hostPort(ip, d.enginePort())},
+	}
+	var listeners []net.Listener
+	stopped := make(chan error, len(forwards))
+	for _, f := range forwards {
+		local := net.JoinHostPort(tunnelHost, strconv.Itoa(f.local))
+		l, err := net.Listen("tcp", local)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("Unable to forward %s of %s from %s, stop the tunnel command running for it : %s", f.remote, d.MachineName, local, err)
+		}
+		log.Infof("Forwarding %s to %s through ssh bastion %s", local, f.remote, d.SSHBastion)
+		listeners = append(listeners, l)
+		go func(l net.Listener, remote string) {
+			stopped <- d.serveForward(l, remote)
+		}(l, f.remote)
+	}
+	return listeners, stopped, nil
+}
+
+// ServeDockerTunnel - forward the docker url and ssh port of a machine behind
+// a bastion until the process is stopped.  Machines created before the ssh
+// port was saved get one here, saved with the machine.
+func (d *Driver) ServeDockerTunnel() error {
+	if d.SSHBastion == "" {
+		return ErrDriverNoBastion
+	}
+	ip, err := d.GetIP()
+	if err != nil {
+		return err
+	}
+	if d.TunnelSSHPort == 0 {
+		if d.TunnelSSHPort, err = freeTunnelPort(d.tunnelPortsInUse()); err != nil {
+			return err
+		}
+		if err := SaveDriver(d); err != nil {
+			return err
+		}
+	}
+	listeners, stopped, err := d.startTunnels(ip)
+	if err != nil {
+		return err
+	}
+	err = <-stopped
+	for _, l := range listeners {
+		l.Close()
+	}
+	return err
+}
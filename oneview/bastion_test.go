@@ -0,0 +1,348 @@
+package oneview
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestParseBastion(t *testing.T) {
+	user, address, err := parseBastion("jump@bastion.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "jump", user)
+	assert.Equal(t, "bastion.example.com:22", address)
+
+	_, address, err = parseBastion("jump@10.0.0.1:2222")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:2222", address)
+
+	_, address, err = parseBastion("jump@[2001:db8::1]")
+	assert.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:22", address)
+
+	for _, v := range []string{"bastion", "@bastion", "jump@", "jump@bastion:0", "jump@bastion:ssh"} {
+		_, _, err = parseBastion(v)
+		assert.Equal(t, ErrDriverBastionFormat, err, v)
+	}
+}
+
+func TestSetBastionConfig(t *testing.T) {
+	d := &Driver{}
+	assert.NoError(t, d.setBastionConfig("jump@bastion", "agent:ops", "SHA256:abc"))
+	assert.Equal(t, "jump@bastion:22", d.SSHBastion)
+	assert.Equal(t, "agent:ops", d.SSHBastionKey)
+	assert.Equal(t, "SHA256:abc", d.SSHBastionHostKey)
+
+	assert.Error(t, d.setBastionConfig("jump@bastion", "/does/not/exist", ""))
+	assert.Equal(t, ErrDriverBastionPin, d.setBastionConfig("jump@bastion", "agent", "MD5:ab:cd"))
+
+	assert.NoError(t, d.setBastionConfig("", "agent", "SHA256:abc"))
+	assert.Equal(t, "", d.SSHBastion)
+	assert.Equal(t, "", d.SSHBastionKey)
+	assert.Equal(t, "", d.SSHBastionHostKey)
+}
+
+// startBastion - ssh server on localhost accepting key and forwarding
+// direct-tcpip channels like a jump host, with the fingerprint of its host key
+func startBastion(t *testing.T, key gossh.PublicKey) (net.Listener, string) {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(conn gossh.ConnMetadata, k gossh.PublicKey) (*gossh.Permissions, error) {
+			if conn.User() == "jump" && string(k.Marshal()) == string(key.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := gossh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go gossh.DiscardRequests(reqs)
+				for nc := range chans {
+					if nc.ChannelType() != "direct-tcpip" {
+						nc.Reject(gossh.UnknownChannelType, "only direct-tcpip")
+						continue
+					}
+					data := nc.ExtraData()
+					n := binary.BigEndian.Uint32(data)
+					host := string(data[4 : 4+n])
+					port := binary.BigEndian.Uint32(data[4+n:])
+					target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+					if err != nil {
+						nc.Reject(gossh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						target.Close()
+						continue
+					}
+					go gossh.DiscardRequests(chReqs)
+					go func() {
+						go io.Copy(ch, target)
+						io.Copy(target, ch)
+						target.Close()
+						ch.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return l, sshKeyFingerprint(signer.PublicKey())
+}
+
+// startEcho - tcp server answering each line with echo: and the line
+func startEcho(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fmt.Fprintf(conn, "echo:%s", line)
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+// roundTrip - send a line to a forwarded port and read the answer
+func roundTrip(t *testing.T, port int) string {
+	conn, err := net.Dial("tcp", net.JoinHostPort(tunnelHost, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "ping\n")
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
+
+func TestBastionForward(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-bastion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "bastion_key")
+	assert.NoError(t, generateSSHKey(keyPath, sshKeyTypeECDSA))
+	data, err := ioutil.ReadFile(keyPath)
+	assert.NoError(t, err)
+	signer, err := gossh.ParsePrivateKey(data)
+	assert.NoError(t, err)
+
+	bastion, fingerprint := startBastion(t, signer.PublicKey())
+	defer bastion.Close()
+	echo := startEcho(t)
+	defer echo.Close()
+	echoPort := echo.Addr().(*net.TCPAddr).Port
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}, SSHPort: echoPort}
+	assert.NoError(t, d.setBastionConfig("jump@"+bastion.Addr().String(), keyPath, fingerprint))
+
+	host, port, err := d.sshEndpoint("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, tunnelHost, host)
+	assert.Equal(t, "echo:ping\n", roundTrip(t, port))
+
+	// the same address is forwarded once
+	_, again, err := d.sshEndpoint("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, port, again)
+
+	// a lost bastion connection is opened again
+	d.bastion.Lock()
+	d.bastion.client.Close()
+	d.bastion.Unlock()
+	assert.Equal(t, "echo:ping\n", roundTrip(t, port))
+
+	// the docker url is read from the configuration, nothing is forwarded
+	d.EnginePort = 40123
+	assert.Equal(t, "tcp://localhost:40123", d.bastionURL())
+	_, forwarded := d.bastion.ports[hostPort("10.0.0.5", d.EnginePort)]
+	assert.False(t, forwarded)
+}
+
+// TestTunnelPorts - each machine behind a bastion gets its own local ports,
+// saved with it, and create forwards them
+func TestTunnelPorts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-bastion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "bastion_key")
+	assert.NoError(t, generateSSHKey(keyPath, sshKeyTypeECDSA))
+	data, err := ioutil.ReadFile(keyPath)
+	assert.NoError(t, err)
+	signer, err := gossh.ParsePrivateKey(data)
+	assert.NoError(t, err)
+	bastion, fingerprint := startBastion(t, signer.PublicKey())
+	defer bastion.Close()
+	echo := startEcho(t)
+	defer echo.Close()
+
+	// another machine of the store already has its ports
+	other := filepath.Join(dir, "machines", "other")
+	assert.NoError(t, os.MkdirAll(other, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(other, "config.json"),
+		[]byte(`{"DriverName": "oneview", "Driver": {"SSHBastion": "jump@bastion:22", "EnginePort": 40001, "TunnelSSHPort": 40002}}`), 0600))
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}, SSHPort: echo.Addr().(*net.TCPAddr).Port, EnginePort: 2376}
+	assert.NoError(t, d.setBastionConfig("jump@"+bastion.Addr().String(), keyPath, fingerprint))
+
+	// no port before create
+	_, err = d.GetSSHPort()
+	assert.Equal(t, ErrDriverNoTunnelPort, err)
+
+	assert.True(t, d.tunnelPortsInUse()[40001])
+	assert.NoError(t, d.allocateTunnelPorts())
+	assert.NotEqual(t, 2376, d.EnginePort)
+	assert.NotEqual(t, d.EnginePort, d.TunnelSSHPort)
+	assert.NotContains(t, []int{40001, 40002}, d.EnginePort)
+	assert.NotContains(t, []int{40001, 40002}, d.TunnelSSHPort)
+	port, err := d.GetSSHPort()
+	assert.NoError(t, err)
+	assert.Equal(t, d.TunnelSSHPort, port)
+
+	// a port given with --oneview-engine-port is kept unless another machine has it
+	given := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "given", StorePath: dir}, SSHBastion: "jump@bastion:22", EnginePort: 40001}
+	assert.Error(t, given.allocateTunnelPorts())
+	given.EnginePort = 40003
+	assert.NoError(t, given.allocateTunnelPorts())
+	assert.Equal(t, 40003, given.EnginePort)
+
+	// create forwards the saved ssh port to the machine
+	listeners, _, err := d.startTunnels("127.0.0.1")
+	assert.NoError(t, err)
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	assert.Equal(t, "echo:ping\n", roundTrip(t, d.TunnelSSHPort))
+
+	// a second process can not take the same ports
+	_, _, err = d.startTunnels("127.0.0.1")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "stop the tunnel command running for it")
+	}
+}
+
+func TestBastionLoginRefused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-bastion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "bastion_key")
+	assert.NoError(t, generateSSHKey(keyPath, sshKeyTypeECDSA))
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherPub, err := gossh.NewPublicKey(&other.PublicKey)
+	assert.NoError(t, err)
+
+	bastion, fingerprint := startBastion(t, otherPub)
+	defer bastion.Close()
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test"}, SSHPort: 22}
+	assert.NoError(t, d.setBastionConfig("jump@"+bastion.Addr().String(), keyPath, fingerprint))
+	err = d.connectBastion()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to login to ssh bastion")
+	}
+	_, _, err = d.sshEndpoint("127.0.0.1")
+	assert.Error(t, err)
+
+	// a bastion with another host key is refused before logging in
+	assert.NoError(t, d.setBastionConfig("jump@"+bastion.Addr().String(), keyPath, "SHA256:other"))
+	err = d.connectBastion()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match --oneview-ssh-bastion-host-key")
+	}
+}
+
+// TestCheckKnownHost - bastion host keys are looked up in known_hosts by
+// plain or hashed host name, with the port when it is not 22
+func TestCheckKnownHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-bastion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	newKey := func() gossh.PublicKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		pub, err := gossh.NewPublicKey(&k.PublicKey)
+		assert.NoError(t, err)
+		return pub
+	}
+	plain, hashed, revoked := newKey(), newKey(), newKey()
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte("[hashed.example.com]:2222"))
+	hashedName := "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	path := filepath.Join(dir, "known_hosts")
+	known := "# bastions\n" +
+		"bastion.example.com,10.0.0.1 " + string(gossh.MarshalAuthorizedKey(plain)) +
+		hashedName + " " + string(gossh.MarshalAuthorizedKey(hashed)) +
+		"@revoked bastion.example.com " + string(gossh.MarshalAuthorizedKey(revoked))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(known), 0600))
+
+	assert.NoError(t, checkKnownHost(path, "bastion.example.com:22", plain))
+	assert.NoError(t, checkKnownHost(path, "10.0.0.1:22", plain))
+	assert.NoError(t, checkKnownHost(path, "hashed.example.com:2222", hashed))
+	assert.Error(t, checkKnownHost(path, "bastion.example.com:2222", plain))
+	assert.Error(t, checkKnownHost(path, "hashed.example.com:22", hashed))
+	assert.Error(t, checkKnownHost(path, "bastion.example.com:22", hashed))
+	assert.Error(t, checkKnownHost(path, "bastion.example.com:22", revoked))
+	assert.Error(t, checkKnownHost(filepath.Join(dir, "missing"), "bastion.example.com:22", plain))
+}
@@ -0,0 +1,142 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/Sheetal-R/oneview-golang/utils"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// bladeCacheFile - per machine cache of the getBlade lookups
+	bladeCacheFile       = "blade-cache.json"
+	defaultBladeCacheTTL = 60 * time.Second
+	// noCacheEnv - set to bypass the cache on any command, not only create
+	noCacheEnv = "ONEVIEW_NO_CACHE"
+)
+
+// bladeCache - resources found by getBlade, so status commands can read them
+// by uri instead of searching the appliances again
+type bladeCache struct {
+	ProfileURI  string    `json:"profileUri"`
+	HardwareURI string    `json:"hardwareUri"`
+	ServerMID   string    `json:"serverMid"`
+	PublicIP    string    `json:"publicIp,omitempty"`
+	Expires     time.Time `json:"expires"`
+}
+
+// bladeCacheTTL - how long getBlade lookups are reused
+func (d *Driver) bladeCacheTTL() time.Duration {
+	if d.BladeCacheTTL <= 0 {
+		return defaultBladeCacheTTL
+	}
+	return d.BladeCacheTTL
+}
+
+// useCache - false when the caches are bypassed with --oneview-no-cache or
+// ONEVIEW_NO_CACHE
+func (d *Driver) useCache() bool {
+	return !d.NoCache && os.Getenv(noCacheEnv) == "" && d.BaseDriver != nil && d.StorePath != ""
+}
+
+// readBladeCache - the cached lookups, false when missing, expired or bypassed
+func (d *Driver) readBladeCache() (c bladeCache, ok bool) {
+	if !d.useCache() {
+		return c, false
+	}
+	data, err := ioutil.ReadFile(d.ResolveStorePath(bladeCacheFile))
+	if err != nil {
+		return c, false
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		log.Debugf("ignoring unreadable blade cache : %s", err)
+		return c, false
+	}
+	if !time.Now().Before(c.Expires) || c.ProfileURI == "" || c.HardwareURI == "" || c.ServerMID == "" {
+		return c, false
+	}
+	return c, true
+}
+
+// saveBladeCache - cache what getBlade found, the public ip is refreshed with
+// every full lookup so a changed address replaces the cached one
+func (d *Driver) saveBladeCache() {
+	if !d.useCache() {
+		return
+	}
+	c := bladeCache{
+		ProfileURI:  d.Profile.URI.String(),
+		HardwareURI: d.Hardware.URI.String(),
+		ServerMID:   d.Server.MID,
+		Expires:     time.Now().Add(d.bladeCacheTTL()),
+	}
+	if ip, err := d.icspIP(); err == nil {
+		c.PublicIP = ip
+	}
+	if old, ok := d.readBladeCache(); ok && old.PublicIP != "" && old.PublicIP != c.PublicIP {
+		log.Debugf("public ip of %s changed from %s to %s", d.MachineName, old.PublicIP, c.PublicIP)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	path := d.ResolveStorePath(bladeCacheFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		log.Debugf("unable to save blade cache : %s", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Debugf("unable to save blade cache : %s", err)
+	}
+}
+
+// invalidateBladeCache - drop the cache after the blade changed state
+func (d *Driver) invalidateBladeCache() {
+	if d.BaseDriver == nil || d.StorePath == "" {
+		return
+	}
+	if err := os.Remove(d.ResolveStorePath(bladeCacheFile)); err != nil && !os.IsNotExist(err) {
+		log.Debugf("unable to remove blade cache : %s", err)
+	}
+}
+
+// cachedIP - the public ip from the cache, empty when it has to be looked up
+func (d *Driver) cachedIP() string {
+	c, ok := d.readBladeCache()
+	if !ok {
+		return ""
+	}
+	return c.PublicIP
+}
+
+// getCachedBlade - reload the hardware and the icsp server from their cached
+// uris.  Only the uris of the profile are set, callers that need the whole
+// profile, create and remove, run after the cache was invalidated.
+func (d *Driver) getCachedBlade(c bladeCache) error {
+	var hw = utils.Nstring(c.HardwareURI)
+	var err error
+	if d.Hardware, err = d.getServerHardware(hw); err != nil {
+		return err
+	}
+	if d.Hardware.URI.IsNil() {
+		return fmt.Errorf("cached server hardware %s not found", c.HardwareURI)
+	}
+
+	var server icsp.Server
+	if err := d.icspCall(rest.GET, "/rest/os-deployment-servers/"+c.ServerMID, nil, &server); err != nil {
+		return err
+	}
+	if server.MID != c.ServerMID {
+		return fmt.Errorf("cached icsp server %s not found", c.ServerMID)
+	}
+	d.Server = server
+	d.Profile.URI = utils.Nstring(c.ProfileURI)
+	d.Profile.ServerHardwareURI = hw
+	return nil
+}
@@ -0,0 +1,72 @@
+package oneview
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/utils"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBladeCache - lookups are cached until they expire, are invalidated or bypassed
+func TestBladeCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "test"), 0700))
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}}
+	d.Profile.URI = utils.Nstring("/rest/server-profiles/1")
+	d.Hardware.URI = utils.Nstring("/rest/server-hardware/1")
+	d.Server.MID = "1234"
+	d.saveBladeCache()
+
+	c, ok := d.readBladeCache()
+	assert.True(t, ok)
+	assert.Equal(t, "/rest/server-profiles/1", c.ProfileURI)
+	assert.Equal(t, "/rest/server-hardware/1", c.HardwareURI)
+	assert.Equal(t, "1234", c.ServerMID)
+	assert.True(t, c.Expires.After(time.Now().Add(defaultBladeCacheTTL-time.Second)))
+
+	// the ip is served from the cache
+	assert.Equal(t, "", d.cachedIP())
+	c.PublicIP = "10.0.0.5"
+	data, _ := json.Marshal(c)
+	assert.NoError(t, ioutil.WriteFile(d.ResolveStorePath(bladeCacheFile), data, 0600))
+	assert.Equal(t, "10.0.0.5", d.cachedIP())
+
+	// bypassed with the flag or the environment
+	d.NoCache = true
+	assert.Equal(t, "", d.cachedIP())
+	d.NoCache = false
+	os.Setenv(noCacheEnv, "1")
+	assert.Equal(t, "", d.cachedIP())
+	os.Unsetenv(noCacheEnv)
+	assert.Equal(t, "10.0.0.5", d.cachedIP())
+
+	// expired
+	c.Expires = time.Now().Add(-time.Second)
+	data, _ = json.Marshal(c)
+	assert.NoError(t, ioutil.WriteFile(d.ResolveStorePath(bladeCacheFile), data, 0600))
+	_, ok = d.readBladeCache()
+	assert.False(t, ok)
+
+	// invalidated
+	d.saveBladeCache()
+	_, ok = d.readBladeCache()
+	assert.True(t, ok)
+	d.invalidateBladeCache()
+	_, ok = d.readBladeCache()
+	assert.False(t, ok)
+
+	// a server not yet in icsp is not cached
+	d.Server.MID = ""
+	d.saveBladeCache()
+	_, ok = d.readBladeCache()
+	assert.False(t, ok)
+}
@@ -0,0 +1,165 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+)
+
+// bond modes for --oneview-bond-mode
+const (
+	bondModeActiveBackup = "active-backup"
+	bondModeLACP         = "802.3ad"
+
+	// bondName - the interface the build plan creates for the bond
+	bondName = "bond0"
+	// noNetworkSpec - network_spec attribute when the build plan keeps the
+	// interfaces as they are
+	noNetworkSpec = "none"
+)
+
+// ErrDriverVLANWithoutBond - vlan tags are set on the bond
+var ErrDriverVLANWithoutBond = errors.New("Option --oneview-vlan-ids needs --oneview-bond-connections, the vlans are tagged on the bond")
+
+// bondSlave - a profile connection in the bond
+type bondSlave struct {
+	Connection string
+	MAC        string
+}
+
+// specSetting - a key=value setting of the network_spec attribute, lists are
+// joined by commas so the build plan reads them with plain shell
+type specSetting struct {
+	Key   string
+	Value string
+}
+
+// joinSpec - network_spec settings separated by spaces, empty values left out
+func joinSpec(settings []specSetting) string {
+	var parts []string
+	for _, s := range settings {
+		if s.Value != "" {
+			parts = append(parts, s.Key+"="+s.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// joinInts - numbers joined by commas
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+// parseBondConnections - connection names of --oneview-bond-connections
+func parseBondConnections(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, n := range strings.Split(value, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("Connection %s is listed twice in --oneview-bond-connections", n)
+		}
+		seen[n] = true
+		names = append(names, n)
+	}
+	if len(names) == 1 {
+		return nil, fmt.Errorf("Option --oneview-bond-connections needs at least two connections, got %s", names[0])
+	}
+	return names, nil
+}
+
+// parseBondMode - value of --oneview-bond-mode, lacp is an alias of 802.3ad
+func parseBondMode(value string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(value)); m {
+	case "", bondModeActiveBackup:
+		return bondModeActiveBackup, nil
+	case "lacp", bondModeLACP:
+		return bondModeLACP, nil
+	}
+	return "", fmt.Errorf("Invalid value %s for --oneview-bond-mode, use active-backup or lacp", value)
+}
+
+// parseVLANs - tags of --oneview-vlan-ids
+func parseVLANs(value string) ([]int, error) {
+	var vlans []int
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 || id > 4094 {
+			return nil, fmt.Errorf("Invalid vlan id %s in --oneview-vlan-ids, use numbers from 1 to 4094", v)
+		}
+		vlans = append(vlans, id)
+	}
+	return vlans, nil
+}
+
+// bondSlaves - the bonded connections of profile with their mac addresses
+func bondSlaves(profile ov.ServerProfile, names []string) ([]bondSlave, error) {
+	slaves := make([]bondSlave, 0, len(names))
+	for _, name := range names {
+		var found *ov.Connection
+		for i, c := range profile.Connections {
+			if c.Name == name {
+				found = &profile.Connections[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("Connection %s from --oneview-bond-connections is not defined in server profile %s", name, profile.Name)
+		}
+		if found.MAC.IsNil() {
+			return nil, fmt.Errorf("Connection %s of server profile %s has no mac address", name, profile.Name)
+		}
+		slaves = append(slaves, bondSlave{Connection: name, MAC: found.MAC.String()})
+	}
+	return slaves, nil
+}
+
+// networkSpec - the network_spec attribute for the build plan, resolved from
+// the server profile of the machine, key=value settings such as
+// bond=bond0 mode=802.3ad slaves=<mac>,<mac> vlans=100.  static is set on the
+// bond, or on the interface with its mac when there is no bond.
+func (d *Driver) networkSpec(static *staticAddress) (string, error) {
+	var settings []specSetting
+	if len(d.BondConnections) > 0 {
+		slaves, err := bondSlaves(d.Profile, d.BondConnections)
+		if err != nil {
+			return "", err
+		}
+		macs := make([]string, len(slaves))
+		for i, s := range slaves {
+			macs[i] = s.MAC
+		}
+		settings = append(settings,
+			specSetting{"bond", bondName},
+			specSetting{"mode", d.BondMode},
+			specSetting{"slaves", strings.Join(macs, ",")},
+			specSetting{"vlans", joinInts(d.VLANs)})
+	}
+	if static != nil {
+		settings = append(settings,
+			specSetting{"mac", static.MAC},
+			specSetting{"address", static.Address},
+			specSetting{"prefix", strconv.Itoa(static.Prefix)},
+			specSetting{"gateway", static.Gateway},
+			specSetting{"dns", strings.Join(static.DNS, ",")},
+			specSetting{"domain", static.Domain})
+	}
+	if len(settings) == 0 {
+		return noNetworkSpec, nil
+	}
+	return joinSpec(settings), nil
+}
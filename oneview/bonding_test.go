@@ -0,0 +1,76 @@
+package oneview
+
+import (
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseBondOptions - bond connections, mode and vlan ids
+func TestParseBondOptions(t *testing.T) {
+	names, err := parseBondConnections("")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+	names, err = parseBondConnections("eth-a, eth-b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eth-a", "eth-b"}, names)
+	_, err = parseBondConnections("eth-a")
+	assert.Error(t, err)
+	_, err = parseBondConnections("eth-a,eth-a")
+	assert.Error(t, err)
+
+	mode, err := parseBondMode("LACP")
+	assert.NoError(t, err)
+	assert.Equal(t, bondModeLACP, mode)
+	_, err = parseBondMode("balance-rr")
+	assert.Error(t, err)
+
+	vlans, err := parseVLANs("100, 200")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{100, 200}, vlans)
+	_, err = parseVLANs("4095")
+	assert.Error(t, err)
+}
+
+// TestNetworkSpec - the bonded connections are resolved to mac addresses
+func TestNetworkSpec(t *testing.T) {
+	d := &Driver{}
+	spec, err := d.networkSpec(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, noNetworkSpec, spec)
+
+	d.Profile = ov.ServerProfile{
+		Name: "test",
+		Connections: []ov.Connection{
+			{Name: "eth-a", MAC: utils.Nstring("00:17:a4:77:00:02")},
+			{Name: "eth-b", MAC: utils.Nstring("00:17:a4:77:00:04")},
+		},
+	}
+	d.BondConnections = []string{"eth-a", "eth-b"}
+	d.BondMode = bondModeLACP
+	d.VLANs = []int{100}
+	spec, err = d.networkSpec(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bond=bond0 mode=802.3ad slaves=00:17:a4:77:00:02,00:17:a4:77:00:04 vlans=100", spec)
+
+	d.VLANs = []int{100, 200}
+	d.BondMode = bondModeActiveBackup
+	spec, err = d.networkSpec(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bond=bond0 mode=active-backup slaves=00:17:a4:77:00:02,00:17:a4:77:00:04 vlans=100,200", spec)
+
+	d.BondConnections = []string{"eth-a", "eth-c"}
+	_, err = d.networkSpec(nil)
+	assert.Error(t, err)
+}
+
+// TestNetworkSpecStatic - a static address without a bond
+func TestNetworkSpecStatic(t *testing.T) {
+	d := &Driver{}
+	spec, err := d.networkSpec(&staticAddress{MAC: "00:17:a4:77:00:02", Address: "10.0.0.20", Prefix: 24, Gateway: "10.0.0.1",
+		DNS: []string{"10.0.0.2", "10.0.0.3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "mac=00:17:a4:77:00:02 address=10.0.0.20 prefix=24 gateway=10.0.0.1 dns=10.0.0.2,10.0.0.3", spec)
+}
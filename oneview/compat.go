@@ -0,0 +1,142 @@
+package oneview
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// API versions the driver speaks, highest first.  Newer appliances still
+// accept these versions, so we ask for the highest one we know the request
+// and response shapes of instead of the appliance current version.  The
+// profile assigned by the library is built for 200 and before, so OneView
+// stays at 200 even on newer appliances.
+var (
+	ovAPIVersions   = []int{200, 120}
+	icspAPIVersions = []int{108}
+)
+
+// ovResourceVersion - OneView API 300, asked only for the resources that
+// first exist or change with it and that the driver reads itself
+const ovResourceVersion = 300
+
+// ovResourcesOf300 - resources requested with ovResourceVersion when the
+// appliance accepts it, the ipv4 subnets and the scoped permissions of users
+var ovResourcesOf300 = []string{"/rest/id-pools/ipv4/", "/rest/users/"}
+
+// supportedCombinations - ICSP API versions tested with each OneView API version
+var supportedCombinations = map[int][]int{
+	120: {108},
+	200: {108},
+}
+
+// ovFeatureSet - OneView features that depend on the API version in use.  The
+// power state request and the profile connections have the same shape in
+// every version we speak, they have no feature here.
+type ovFeatureSet struct {
+	// ServerProfileTemplates - templates are server profile templates, before
+	// 200 they are unassigned server profiles
+	ServerProfileTemplates bool
+	// IPv4Subnets - OneView manages ipv4 subnets and address ranges
+	IPv4Subnets bool
+}
+
+// ovFeatures - features available with the OneView API version in use
+func (d *Driver) ovFeatures() ovFeatureSet {
+	return ovFeatureSet{
+		ServerProfileTemplates: d.ClientOV.APIVersion >= 200,
+		IPv4Subnets:            d.ovResourceAPI >= ovResourceVersion,
+	}
+}
+
+// requestVersion - X-API-Version of a driver request, the negotiated version
+// or ovResourceVersion for the resources of ovResourcesOf300
+func (d *Driver) requestVersion(appliance string, uri string) int {
+	c := d.applianceClient(appliance)
+	if appliance != applianceOV || d.ovResourceAPI == 0 {
+		return c.APIVersion
+	}
+	for _, prefix := range ovResourcesOf300 {
+		if strings.HasPrefix(uri, prefix) {
+			return d.ovResourceAPI
+		}
+	}
+	return c.APIVersion
+}
+
+// joinVersions - version list for messages
+func joinVersions(versions []int) string {
+	s := make([]string, len(versions))
+	for i, v := range versions {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// negotiateVersion - the highest supported version the appliance accepts
+func negotiateVersion(appliance string, supported []int, current int, minimum int) (int, error) {
+	if current <= 0 {
+		return 0, fmt.Errorf("Unable to get a valid version from %s, current version %d", appliance, current)
+	}
+	for _, v := range supported {
+		if v <= current && v >= minimum {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%s API versions %d to %d are not supported by this driver, supported versions are %s",
+		appliance, minimum, current, joinVersions(supported))
+}
+
+// isSupportedCombination - check the OneView and ICSP versions work together
+func isSupportedCombination(ovVersion int, icspVersion int) bool {
+	for _, v := range supportedCombinations[ovVersion] {
+		if v == icspVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateAPIVersions - pick the API version used with each appliance and
+// refuse appliances or combinations the driver does not support
+func (d *Driver) negotiateAPIVersions() error {
+	var (
+		ovVersion   ov.APIVersion
+		icspVersion icsp.APIVersion
+	)
+	if err := d.applianceGet(applianceOV, versionURI, &ovVersion); err != nil {
+		return err
+	}
+	if err := d.applianceGet(applianceICSP, versionURI, &icspVersion); err != nil {
+		return err
+	}
+
+	ovAPI, err := negotiateVersion("OneView", ovAPIVersions, ovVersion.CurrentVersion, ovVersion.MinimumVersion)
+	if err != nil {
+		return err
+	}
+	icspAPI, err := negotiateVersion("ICSP", icspAPIVersions, icspVersion.CurrentVersion, icspVersion.MinimumVersion)
+	if err != nil {
+		return err
+	}
+	if !isSupportedCombination(ovAPI, icspAPI) {
+		return fmt.Errorf("OneView API version %d with ICSP API version %d is not a supported combination, ICSP versions supported with this OneView are %s",
+			ovAPI, icspAPI, joinVersions(supportedCombinations[ovAPI]))
+	}
+
+	// the subnets and scoped roles are read with 300 when the appliance has it
+	d.ovResourceAPI = 0
+	if ovVersion.MinimumVersion <= ovResourceVersion && ovVersion.CurrentVersion >= ovResourceVersion {
+		d.ovResourceAPI = ovResourceVersion
+	}
+
+	log.Debugf("using OneView API %d (appliance %d) and ICSP API %d (appliance %d)",
+		ovAPI, ovVersion.CurrentVersion, icspAPI, icspVersion.CurrentVersion)
+	d.ClientOV.APIVersion = ovAPI
+	d.ClientICSP.APIVersion = icspAPI
+	return nil
+}
@@ -0,0 +1,60 @@
+package oneview
+
+import (
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiateVersion - the highest version both sides accept is used
+func TestNegotiateVersion(t *testing.T) {
+	v, err := negotiateVersion("OneView", ovAPIVersions, 120, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 120, v)
+
+	v, err = negotiateVersion("OneView", ovAPIVersions, 201, 120)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, v)
+
+	v, err = negotiateVersion("OneView", ovAPIVersions, 800, 120)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, v)
+
+	_, err = negotiateVersion("OneView", ovAPIVersions, 1000, 500)
+	assert.EqualError(t, err, "OneView API versions 500 to 1000 are not supported by this driver, supported versions are 200, 120")
+
+	_, err = negotiateVersion("ICSP", icspAPIVersions, 104, 1)
+	assert.Error(t, err)
+
+	_, err = negotiateVersion("ICSP", icspAPIVersions, -1, 0)
+	assert.Error(t, err)
+}
+
+// TestSupportedCombination - every supported OneView version has an ICSP version
+func TestSupportedCombination(t *testing.T) {
+	for _, v := range ovAPIVersions {
+		assert.True(t, isSupportedCombination(v, 108), "OneView %d with ICSP 108", v)
+	}
+	assert.False(t, isSupportedCombination(500, 108))
+	assert.False(t, isSupportedCombination(200, 104))
+}
+
+// TestRequestVersion - the subnets and users are asked with 300 when the
+// appliance has it, everything else with the negotiated version
+func TestRequestVersion(t *testing.T) {
+	d := &Driver{ClientOV: &ov.OVClient{}, ClientICSP: &icsp.ICSPClient{}}
+	d.ClientOV.APIVersion = 200
+	d.ClientICSP.APIVersion = 108
+	assert.Equal(t, 200, d.requestVersion(applianceOV, ipv4SubnetsURI))
+	assert.False(t, d.ovFeatures().IPv4Subnets)
+
+	d.ovResourceAPI = ovResourceVersion
+	assert.Equal(t, 300, d.requestVersion(applianceOV, ipv4SubnetsURI))
+	assert.Equal(t, 300, d.requestVersion(applianceOV, "/rest/users/admin"))
+	assert.Equal(t, 200, d.requestVersion(applianceOV, "/rest/server-profiles"))
+	assert.Equal(t, 108, d.requestVersion(applianceICSP, "/rest/users/admin"))
+	assert.True(t, d.ovFeatures().IPv4Subnets)
+}
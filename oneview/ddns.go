@@ -0,0 +1,609 @@
+package oneview
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// ddnsStateFile - the name and address registered for the machine
+	ddnsStateFile = "ddns.json"
+	// ddnsTSIGSecret - name of the TSIG secret in the machine secrets
+	ddnsTSIGSecret   = "ddnsKeySecret"
+	defaultDDNSPort  = "53"
+	defaultDDNSTTL   = 300
+	defaultTSIGAlgo  = "hmac-sha256"
+	ddnsTimeout      = 5 * time.Second
+	tsigFudgeSeconds = 300
+
+	dnsTypeA    = 1
+	dnsTypeSOA  = 6
+	dnsTypePTR  = 12
+	dnsTypeAAAA = 28
+	dnsTypeTSIG = 250
+
+	dnsClassIN  = 1
+	dnsClassANY = 255
+
+	dnsOpcodeUpdate = 5
+)
+
+// ErrDriverDDNSOptions - dynamic dns needs a zone and a key
+var ErrDriverDDNSOptions = errors.New("Option --oneview-ddns-server needs --oneview-ddns-zone, --oneview-ddns-key-name and --oneview-ddns-key-secret")
+
+// tsigAlgorithms - TSIG algorithm names and their hash
+var tsigAlgorithms = map[string]struct {
+	name string
+	hash func() hash.Hash
+}{
+	"hmac-md5":    {"hmac-md5.sig-alg.reg.int.", md5.New},
+	"hmac-sha1":   {"hmac-sha1.", sha1.New},
+	"hmac-sha256": {"hmac-sha256.", sha256.New},
+	"hmac-sha512": {"hmac-sha512.", sha512.New},
+}
+
+// dnsRcodes - names of the update response codes and TSIG errors
+var dnsRcodes = map[int]string{
+	1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED",
+	6: "YXDOMAIN", 7: "YXRRSET", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE",
+	16: "BADSIG", 17: "BADKEY", 18: "BADTIME",
+}
+
+// tsigKey - key signing the updates
+type tsigKey struct {
+	Name      string
+	Algorithm string
+	Secret    []byte
+}
+
+// dnsRR - a resource record of the update section
+type dnsRR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// dnsUpdate - an RFC 2136 update of one zone
+type dnsUpdate struct {
+	Zone    string
+	Updates []dnsRR
+}
+
+// ddnsState - what is registered for the machine
+type ddnsState struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// fqdn - name with the trailing dot
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// packName - uncompressed wire form of a domain name
+func packName(name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	var b []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid dns name %s", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	b = append(b, 0)
+	if len(b) > 255 {
+		return nil, fmt.Errorf("dns name %s is too long", name)
+	}
+	return b, nil
+}
+
+// appendRR - wire form of a record
+func appendRR(b []byte, rr dnsRR) ([]byte, error) {
+	name, err := packName(rr.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, name...)
+	var fixed [10]byte
+	binary.BigEndian.PutUint16(fixed[0:], rr.Type)
+	binary.BigEndian.PutUint16(fixed[2:], rr.Class)
+	binary.BigEndian.PutUint32(fixed[4:], rr.TTL)
+	binary.BigEndian.PutUint16(fixed[8:], uint16(len(rr.Data)))
+	b = append(b, fixed[:]...)
+	return append(b, rr.Data...), nil
+}
+
+// pack - the update message without signature
+func (u dnsUpdate) pack(id uint16) ([]byte, error) {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], dnsOpcodeUpdate<<11)
+	binary.BigEndian.PutUint16(b[4:], 1)
+	binary.BigEndian.PutUint16(b[8:], uint16(len(u.Updates)))
+	zone, err := packName(u.Zone)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, zone...)
+	b = append(b, 0, dnsTypeSOA, 0, dnsClassIN)
+	for _, rr := range u.Updates {
+		if b, err = appendRR(b, rr); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// tsigMAC - RFC 2845 signature of msg.  Answers are signed after the MAC of
+// the request, prior, along with their TSIG error and other data.
+func (k tsigKey) tsigMAC(prior []byte, msg []byte, signed time.Time, tsigError uint16, other []byte) ([]byte, error) {
+	algo, ok := tsigAlgorithms[k.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tsig algorithm %s", k.Algorithm)
+	}
+	keyName, err := packName(strings.ToLower(k.Name))
+	if err != nil {
+		return nil, err
+	}
+	algoName, _ := packName(algo.name)
+
+	mac := hmac.New(algo.hash, k.Secret)
+	if prior != nil {
+		mac.Write([]byte{byte(len(prior) >> 8), byte(len(prior))})
+		mac.Write(prior)
+	}
+	mac.Write(msg)
+	mac.Write(keyName)
+	mac.Write([]byte{0, dnsClassANY, 0, 0, 0, 0})
+	mac.Write(algoName)
+	mac.Write(tsigTime(signed))
+	// fudge, error, other len and other data
+	mac.Write([]byte{byte(tsigFudgeSeconds >> 8), byte(tsigFudgeSeconds & 0xff), byte(tsigError >> 8), byte(tsigError)})
+	mac.Write([]byte{byte(len(other) >> 8), byte(len(other))})
+	mac.Write(other)
+	return mac.Sum(nil), nil
+}
+
+// tsigTime - 48 bit time signed
+func tsigTime(t time.Time) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(t.Unix()))
+	return b[2:]
+}
+
+// sign - msg with its TSIG record appended and the MAC, prior is the MAC of
+// the request when msg is an answer
+func (k tsigKey) sign(msg []byte, signed time.Time, prior []byte) ([]byte, []byte, error) {
+	mac, err := k.tsigMAC(prior, msg, signed, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	algoName, _ := packName(tsigAlgorithms[k.Algorithm].name)
+	data := append([]byte{}, algoName...)
+	data = append(data, tsigTime(signed)...)
+	data = append(data, byte(tsigFudgeSeconds>>8), byte(tsigFudgeSeconds&0xff))
+	data = append(data, byte(len(mac)>>8), byte(len(mac)))
+	data = append(data, mac...)
+	data = append(data, msg[0], msg[1], 0, 0, 0, 0)
+
+	signedMsg := append([]byte{}, msg...)
+	binary.BigEndian.PutUint16(signedMsg[10:], binary.BigEndian.Uint16(msg[10:])+1)
+	signedMsg, err = appendRR(signedMsg, dnsRR{Name: strings.ToLower(k.Name), Type: dnsTypeTSIG, Class: dnsClassANY, Data: data})
+	return signedMsg, mac, err
+}
+
+// skipName - offset after the name at off, compressed or not
+func skipName(msg []byte, off int) (int, error) {
+	for off < len(msg) {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			return off + 2, nil
+		case l > 63:
+			return 0, errors.New("bad label in answer")
+		}
+		off += l + 1
+	}
+	return 0, errors.New("short answer")
+}
+
+// skipRR - offset after the record at off, and the offset of its data
+func skipRR(msg []byte, off int) (int, int, error) {
+	off, err := skipName(msg, off)
+	if err != nil || off+10 > len(msg) {
+		return 0, 0, errors.New("short answer")
+	}
+	end := off + 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+	if end > len(msg) {
+		return 0, 0, errors.New("short answer")
+	}
+	return end, off, nil
+}
+
+// verify - check the TSIG record closing the answer to a request signed with
+// prior, RFC 2845 section 4.  An unsigned answer, a TSIG error, a wrong MAC
+// or a time out of the fudge is an error.
+func (k tsigKey) verify(resp []byte, prior []byte, now time.Time) error {
+	if len(resp) < 12 || binary.BigEndian.Uint16(resp[10:]) == 0 {
+		return errors.New("the answer is not signed")
+	}
+	off, err := skipName(resp, 12)
+	if err != nil {
+		return err
+	}
+	off += 4
+	records := int(binary.BigEndian.Uint16(resp[6:])) + int(binary.BigEndian.Uint16(resp[8:])) + int(binary.BigEndian.Uint16(resp[10:])) - 1
+	for i := 0; i < records; i++ {
+		if off, _, err = skipRR(resp, off); err != nil {
+			return err
+		}
+	}
+	tsigStart := off
+	end, fixed, err := skipRR(resp, off)
+	if err != nil || end != len(resp) || binary.BigEndian.Uint16(resp[fixed:]) != dnsTypeTSIG {
+		return errors.New("the answer is not signed")
+	}
+
+	// algorithm, time signed, fudge, mac, original id, error and other data
+	data := resp[fixed+10 : end]
+	p, err := skipName(data, 0)
+	if err != nil || p+10 > len(data) {
+		return errors.New("bad tsig record in the answer")
+	}
+	algoName, _ := packName(tsigAlgorithms[k.Algorithm].name)
+	if !strings.EqualFold(string(data[:p]), string(algoName)) {
+		return errors.New("the answer is signed with another tsig algorithm")
+	}
+	signed := time.Unix(int64(binary.BigEndian.Uint64(append([]byte{0, 0}, data[p:p+6]...))), 0)
+	fudge := time.Duration(binary.BigEndian.Uint16(data[p+6:])) * time.Second
+	macEnd := p + 10 + int(binary.BigEndian.Uint16(data[p+8:]))
+	if macEnd+6 > len(data) {
+		return errors.New("bad tsig record in the answer")
+	}
+	mac := data[p+10 : macEnd]
+	originalID := data[macEnd : macEnd+2]
+	tsigError := binary.BigEndian.Uint16(data[macEnd+2:])
+	otherEnd := macEnd + 6 + int(binary.BigEndian.Uint16(data[macEnd+4:]))
+	if otherEnd != len(data) {
+		return errors.New("bad tsig record in the answer")
+	}
+	if tsigError != 0 {
+		return fmt.Errorf("tsig error %s", rcodeName(int(tsigError)))
+	}
+
+	unsigned := append([]byte{}, resp[:tsigStart]...)
+	copy(unsigned[0:], originalID)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(resp[10:])-1)
+	want, err := k.tsigMAC(prior, unsigned, signed, 0, data[macEnd+6:otherEnd])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, want) {
+		return errors.New("the tsig signature of the answer does not match the key")
+	}
+	if now.Sub(signed) > fudge || signed.Sub(now) > fudge {
+		return fmt.Errorf("the answer is signed at %s, more than %s away from now", signed.UTC().Format(time.RFC3339), fudge)
+	}
+	return nil
+}
+
+// rcodeName - name of a response code or TSIG error
+func rcodeName(rcode int) string {
+	if name := dnsRcodes[rcode]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("rcode %d", rcode)
+}
+
+// exchangeUDP - send msg over udp, the answer with the id of msg
+func exchangeUDP(server string, msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", server, ddnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ddnsTimeout))
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 12 && resp[0] == msg[0] && resp[1] == msg[1] {
+			return resp[:n], nil
+		}
+	}
+}
+
+// exchangeTCP - send msg over tcp, each message preceded by its length
+func exchangeTCP(server string, msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", server, ddnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ddnsTimeout))
+	if _, err := conn.Write(append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...)); err != nil {
+		return nil, err
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 || resp[0] != msg[0] || resp[1] != msg[1] {
+		return nil, errors.New("answer to another message")
+	}
+	return resp, nil
+}
+
+// sendUpdate - send a signed update and check the signed answer.  The update
+// goes over udp, and again over tcp when udp gets no answer or a truncated
+// one.
+func sendUpdate(server string, key tsigKey, u dnsUpdate) error {
+	var idb [2]byte
+	rand.Read(idb[:])
+	msg, err := u.pack(binary.BigEndian.Uint16(idb[:]))
+	if err != nil {
+		return err
+	}
+	msg, mac, err := key.sign(msg, time.Now(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := exchangeUDP(server, msg)
+	if err != nil || resp[2]&0x02 != 0 {
+		log.Debugf("sending the update of %s to %s over tcp, udp answer truncated or missing : %v", u.Zone, server, err)
+		if resp, err = exchangeTCP(server, msg); err != nil {
+			return fmt.Errorf("no answer from dns server %s : %s", server, err)
+		}
+	}
+	rcode := int(resp[3] & 0x0f)
+	if err := key.verify(resp, mac, time.Now()); err != nil {
+		if rcode != 0 {
+			return fmt.Errorf("dns server %s refused the update of %s : %s, %s", server, u.Zone, rcodeName(rcode), err)
+		}
+		return fmt.Errorf("dns server %s answered the update of %s but %s", server, u.Zone, err)
+	}
+	if rcode != 0 {
+		return fmt.Errorf("dns server %s refused the update of %s : %s", server, u.Zone, rcodeName(rcode))
+	}
+	return nil
+}
+
+// reverseName - the PTR name of ip
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0])
+	}
+	const hexDigits = "0123456789abcdef"
+	var labels []string
+	for i := len(ip) - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip[i]&0x0f]), string(hexDigits[ip[i]>>4]))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa."
+}
+
+// defaultReverseZone - the /24 or /64 reverse zone of ip
+func defaultReverseZone(ip net.IP) string {
+	labels := strings.Split(strings.TrimSuffix(reverseName(ip), "."), ".")
+	if ip.To4() != nil {
+		return strings.Join(labels[1:], ".") + "."
+	}
+	return strings.Join(labels[16:], ".") + "."
+}
+
+// addressRR - the A or AAAA record of ip
+func addressRR(name string, ip net.IP) dnsRR {
+	if v4 := ip.To4(); v4 != nil {
+		return dnsRR{Name: name, Type: dnsTypeA, Class: dnsClassIN, TTL: defaultDDNSTTL, Data: v4}
+	}
+	return dnsRR{Name: name, Type: dnsTypeAAAA, Class: dnsClassIN, TTL: defaultDDNSTTL, Data: ip.To16()}
+}
+
+// deleteRRset - update record removing every record of a type
+func deleteRRset(name string, rrtype uint16) dnsRR {
+	return dnsRR{Name: name, Type: rrtype, Class: dnsClassANY}
+}
+
+// checkDDNS - options dynamic dns needs
+func (d *Driver) checkDDNS() error {
+	if d.DDNSServer == "" {
+		return nil
+	}
+	if d.DDNSZone == "" || d.DDNSKeyName == "" || d.ddnsKeySecret == "" {
+		return ErrDriverDDNSOptions
+	}
+	if _, ok := tsigAlgorithms[d.DDNSKeyAlgorithm]; !ok {
+		return fmt.Errorf("Invalid value %s for --oneview-ddns-key-algorithm, use hmac-md5, hmac-sha1, hmac-sha256 or hmac-sha512", d.DDNSKeyAlgorithm)
+	}
+	if _, err := base64.StdEncoding.DecodeString(d.ddnsKeySecret); err != nil {
+		return fmt.Errorf("Option --oneview-ddns-key-secret must be base64 encoded : %s", err)
+	}
+	return nil
+}
+
+// ddnsServer - host:port of the dns server
+func (d *Driver) ddnsServer() string {
+	if _, _, err := net.SplitHostPort(d.DDNSServer); err == nil {
+		return d.DDNSServer
+	}
+	return net.JoinHostPort(strings.Trim(d.DDNSServer, "[]"), defaultDDNSPort)
+}
+
+// saveDDNSSecret - keep the TSIG secret in the machine secrets, out of
+// config.json
+func (d *Driver) saveDDNSSecret() error {
+	if d.DDNSServer == "" || d.ddnsKeySecret == "" {
+		return nil
+	}
+	return d.saveSecret(ddnsTSIGSecret, d.ddnsKeySecret)
+}
+
+// ddnsKey - the TSIG key of the options, its secret read from the machine
+// secrets after create
+func (d *Driver) ddnsKey() tsigKey {
+	encoded := d.ddnsKeySecret
+	if encoded == "" {
+		var err error
+		if encoded, err = d.getSecret(ddnsTSIGSecret); err != nil {
+			log.Warnf("Unable to read the --oneview-ddns-key-secret : %s", err)
+		}
+	}
+	secret, _ := base64.StdEncoding.DecodeString(encoded)
+	algorithm := d.DDNSKeyAlgorithm
+	if algorithm == "" {
+		algorithm = defaultTSIGAlgo
+	}
+	return tsigKey{Name: d.DDNSKeyName, Algorithm: algorithm, Secret: secret}
+}
+
+// ddnsName - fully qualified name registered for the machine, the dns ip
+// source name within the zone
+func (d *Driver) ddnsName() string {
+	name, zone := strings.TrimSuffix(d.dnsName(), "."), strings.TrimSuffix(d.DDNSZone, ".")
+	if name == zone || strings.HasSuffix(name, "."+zone) {
+		return fqdn(name)
+	}
+	return fqdn(name + "." + zone)
+}
+
+// reverseZone - zone of the PTR record of ip
+func (d *Driver) reverseZone(ip net.IP) string {
+	if d.DDNSReverseZone != "" {
+		return fqdn(d.DDNSReverseZone)
+	}
+	return defaultReverseZone(ip)
+}
+
+// readDDNSState - what was registered, empty when nothing was
+func (d *Driver) readDDNSState() ddnsState {
+	var s ddnsState
+	if d.BaseDriver == nil || d.StorePath == "" {
+		return s
+	}
+	data, err := ioutil.ReadFile(d.ResolveStorePath(ddnsStateFile))
+	if err == nil {
+		json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+// saveDDNSState - remember what is registered
+func (d *Driver) saveDDNSState(s ddnsState) {
+	if d.BaseDriver == nil || d.StorePath == "" {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(d.ResolveStorePath(ddnsStateFile), data, 0600); err != nil {
+		log.Debugf("unable to save dns registration : %s", err)
+	}
+}
+
+// registerDNS - point the machine name at ip, and ip back at the name,
+// nothing is sent when they are already registered
+func (d *Driver) registerDNS(address string) error {
+	ip := net.ParseIP(address)
+	if d.DDNSServer == "" || ip == nil {
+		return nil
+	}
+	name := d.ddnsName()
+	old := d.readDDNSState()
+	if old.Name == name && old.Address == address {
+		return nil
+	}
+	server, key := d.ddnsServer(), d.ddnsKey()
+
+	forward := dnsUpdate{Zone: fqdn(d.DDNSZone), Updates: []dnsRR{
+		deleteRRset(name, dnsTypeA),
+		deleteRRset(name, dnsTypeAAAA),
+		addressRR(name, ip),
+	}}
+	if old.Name != "" && old.Name != name {
+		forward.Updates = append(forward.Updates, deleteRRset(old.Name, dnsTypeA), deleteRRset(old.Name, dnsTypeAAAA))
+	}
+	if err := sendUpdate(server, key, forward); err != nil {
+		return err
+	}
+	d.saveDDNSState(ddnsState{Name: name, Address: address})
+
+	if oldIP := net.ParseIP(old.Address); oldIP != nil && old.Address != address {
+		if err := sendUpdate(server, key, dnsUpdate{Zone: d.reverseZone(oldIP), Updates: []dnsRR{
+			deleteRRset(reverseName(oldIP), dnsTypePTR),
+		}}); err != nil {
+			log.Warnf("Unable to remove the PTR record of %s : %s", old.Address, err)
+		}
+	}
+	target, _ := packName(name)
+	ptr := reverseName(ip)
+	if err := sendUpdate(server, key, dnsUpdate{Zone: d.reverseZone(ip), Updates: []dnsRR{
+		deleteRRset(ptr, dnsTypePTR),
+		{Name: ptr, Type: dnsTypePTR, Class: dnsClassIN, TTL: defaultDDNSTTL, Data: target},
+	}}); err != nil {
+		return fmt.Errorf("Registered %s as %s but not its PTR record : %s", name, address, err)
+	}
+	log.Infof("Registered %s as %s in dns", name, address)
+	return nil
+}
+
+// unregisterDNS - remove the records of the machine
+func (d *Driver) unregisterDNS() error {
+	s := d.readDDNSState()
+	if d.DDNSServer == "" || s.Name == "" {
+		return nil
+	}
+	server, key := d.ddnsServer(), d.ddnsKey()
+	if err := sendUpdate(server, key, dnsUpdate{Zone: fqdn(d.DDNSZone), Updates: []dnsRR{
+		deleteRRset(s.Name, dnsTypeA),
+		deleteRRset(s.Name, dnsTypeAAAA),
+	}}); err != nil {
+		return err
+	}
+	if ip := net.ParseIP(s.Address); ip != nil {
+		if err := sendUpdate(server, key, dnsUpdate{Zone: d.reverseZone(ip), Updates: []dnsRR{
+			deleteRRset(reverseName(ip), dnsTypePTR),
+		}}); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(d.ResolveStorePath(ddnsStateFile)); err != nil && !os.IsNotExist(err) {
+		log.Debugf("unable to remove dns registration : %s", err)
+	}
+	log.Infof("Removed %s from dns", s.Name)
+	return nil
+}
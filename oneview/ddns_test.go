@@ -0,0 +1,485 @@
+package oneview
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDDNSSecret - base64 secret of the stub dns server key
+var testDDNSSecret = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+// receivedUpdate - zone and records of an update the stub accepted
+type receivedUpdate struct {
+	Zone    string
+	Updates []dnsRR
+}
+
+// ddnsServerStub - udp and tcp dns server checking the TSIG signature of
+// updates and signing its answers
+type ddnsServerStub struct {
+	sync.Mutex
+	conn     net.PacketConn
+	tcp      net.Listener
+	refuse   string
+	truncate bool
+	unsigned bool
+	overTCP  int
+	updates  []receivedUpdate
+}
+
+func newDDNSServerStub(t *testing.T) *ddnsServerStub {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ddnsServerStub{conn: conn, tcp: l}
+	go s.serve()
+	go s.serveTCP()
+	return s
+}
+
+// Close - stop both listeners
+func (s *ddnsServerStub) Close() {
+	s.conn.Close()
+	s.tcp.Close()
+}
+
+func (s *ddnsServerStub) serve() {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg := append([]byte{}, buf[:n]...)
+		s.Lock()
+		truncate := s.truncate
+		s.Unlock()
+		if truncate {
+			resp := append([]byte{}, msg[:12]...)
+			resp[2] |= 0x82
+			s.conn.WriteTo(resp, addr)
+			continue
+		}
+		s.conn.WriteTo(s.answer(msg), addr)
+	}
+}
+
+func (s *ddnsServerStub) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err == nil {
+			msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(conn, msg); err == nil {
+				s.Lock()
+				s.overTCP++
+				s.Unlock()
+				resp := s.answer(msg)
+				conn.Write(append([]byte{byte(len(resp) >> 8), byte(len(resp))}, resp...))
+			}
+		}
+		conn.Close()
+	}
+}
+
+// answer - the answer to the update in msg, signed after the request MAC
+// when the request signature checks out
+func (s *ddnsServerStub) answer(msg []byte) []byte {
+	rcode := byte(0)
+	u, mac, err := parseSignedUpdate(msg)
+	s.Lock()
+	defer s.Unlock()
+	switch {
+	case err != nil:
+		rcode = 9
+	case s.refuse != "" && u.Zone == s.refuse:
+		rcode = 5
+	default:
+		s.updates = append(s.updates, u)
+	}
+	resp := []byte{msg[0], msg[1], 0xa8, rcode, 0, 1, 0, 0, 0, 0, 0, 0}
+	zone, _ := packName(u.Zone)
+	resp = append(append(resp, zone...), 0, dnsTypeSOA, 0, dnsClassIN)
+	if err != nil || s.unsigned {
+		return resp
+	}
+	secret, _ := base64.StdEncoding.DecodeString(testDDNSSecret)
+	signed, _, _ := tsigKey{Name: "test-key", Algorithm: "hmac-sha256", Secret: secret}.sign(resp, time.Now(), mac)
+	return signed
+}
+
+func (s *ddnsServerStub) received() []receivedUpdate {
+	s.Lock()
+	defer s.Unlock()
+	return append([]receivedUpdate{}, s.updates...)
+}
+
+// readName - an uncompressed name at off
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("short name")
+		}
+		l := int(msg[off])
+		off++
+		if l == 0 {
+			return strings.Join(labels, ".") + ".", off, nil
+		}
+		if l > 63 || off+l > len(msg) {
+			return "", 0, errors.New("bad label")
+		}
+		labels = append(labels, string(msg[off:off+l]))
+		off += l
+	}
+}
+
+// readRR - the record at off
+func readRR(msg []byte, off int) (dnsRR, int, error) {
+	name, off, err := readName(msg, off)
+	if err != nil || off+10 > len(msg) {
+		return dnsRR{}, 0, errors.New("short record")
+	}
+	rr := dnsRR{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(msg[off:]),
+		Class: binary.BigEndian.Uint16(msg[off+2:]),
+		TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	l := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+l > len(msg) {
+		return dnsRR{}, 0, errors.New("short rdata")
+	}
+	rr.Data = msg[off : off+l]
+	return rr, off + l, nil
+}
+
+// parseSignedUpdate - the update in msg and its MAC once its hmac-sha256
+// signature by test-key checks out
+func parseSignedUpdate(msg []byte) (receivedUpdate, []byte, error) {
+	var u receivedUpdate
+	if len(msg) < 12 || msg[2]>>3 != dnsOpcodeUpdate || binary.BigEndian.Uint16(msg[4:]) != 1 ||
+		binary.BigEndian.Uint16(msg[10:]) != 1 {
+		return u, nil, errors.New("not a signed update")
+	}
+	zone, off, err := readName(msg, 12)
+	if err != nil {
+		return u, nil, err
+	}
+	u.Zone = zone
+	off += 4
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[8:])); i++ {
+		var rr dnsRR
+		if rr, off, err = readRR(msg, off); err != nil {
+			return u, nil, err
+		}
+		u.Updates = append(u.Updates, rr)
+	}
+	tsigStart := off
+	tsig, _, err := readRR(msg, off)
+	if err != nil || tsig.Type != dnsTypeTSIG || tsig.Name != "test-key." {
+		return u, nil, errors.New("no tsig record")
+	}
+	algo, p, err := readName(tsig.Data, 0)
+	if err != nil || algo != "hmac-sha256." {
+		return u, nil, errors.New("bad tsig algorithm")
+	}
+	timeFudge := tsig.Data[p : p+8]
+	macLen := int(binary.BigEndian.Uint16(tsig.Data[p+8:]))
+	mac := tsig.Data[p+10 : p+10+macLen]
+
+	unsigned := append([]byte{}, msg[:tsigStart]...)
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+	secret, _ := base64.StdEncoding.DecodeString(testDDNSSecret)
+	h := hmac.New(sha256.New, secret)
+	h.Write(unsigned)
+	h.Write(append([]byte{8}, "test-key"...))
+	h.Write([]byte{0, 0, 255, 0, 0, 0, 0})
+	h.Write(append([]byte{11}, "hmac-sha256"...))
+	h.Write([]byte{0})
+	h.Write(timeFudge)
+	h.Write([]byte{0, 0, 0, 0})
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return u, nil, errors.New("bad signature")
+	}
+	return u, mac, nil
+}
+
+func newDDNSDriver(t *testing.T, server string) *Driver {
+	dir, err := ioutil.TempDir("", "oneview-ddns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "machines", "node1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return &Driver{
+		BaseDriver:       &drivers.BaseDriver{MachineName: "node1", StorePath: dir},
+		DDNSServer:       server,
+		DDNSZone:         "example.com",
+		DDNSKeyName:      "test-key",
+		ddnsKeySecret:    testDDNSSecret,
+		DDNSKeyAlgorithm: defaultTSIGAlgo,
+	}
+}
+
+func TestPackName(t *testing.T) {
+	b, err := packName("node1.example.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, append(append(append([]byte{5}, "node1"...), 7), append([]byte("example"), 3, 'c', 'o', 'm', 0)...), b)
+
+	b, err = packName(".")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0}, b)
+
+	_, err = packName("a..b")
+	assert.Error(t, err)
+	_, err = packName(strings.Repeat("a", 64) + ".com")
+	assert.Error(t, err)
+}
+
+func TestReverseName(t *testing.T) {
+	ip := net.ParseIP("10.0.1.20")
+	assert.Equal(t, "20.1.0.10.in-addr.arpa.", reverseName(ip))
+	assert.Equal(t, "1.0.10.in-addr.arpa.", defaultReverseZone(ip))
+
+	ip = net.ParseIP("2001:db8::1")
+	assert.Equal(t, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", reverseName(ip))
+	assert.Equal(t, "0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", defaultReverseZone(ip))
+}
+
+func TestDDNSNameAndServer(t *testing.T) {
+	d := newDDNSDriver(t, "10.0.0.2")
+	defer os.RemoveAll(d.StorePath)
+	assert.Equal(t, "node1.example.com.", d.ddnsName())
+	assert.Equal(t, "10.0.0.2:53", d.ddnsServer())
+
+	d.DNSName = "docker1.example.com"
+	assert.Equal(t, "docker1.example.com.", d.ddnsName())
+
+	d.DDNSServer = "[2001:db8::2]"
+	assert.Equal(t, "[2001:db8::2]:53", d.ddnsServer())
+	d.DDNSServer = "10.0.0.2:5353"
+	assert.Equal(t, "10.0.0.2:5353", d.ddnsServer())
+}
+
+func TestCheckDDNS(t *testing.T) {
+	d := newDDNSDriver(t, "10.0.0.2")
+	defer os.RemoveAll(d.StorePath)
+	assert.NoError(t, d.checkDDNS())
+
+	d.DDNSKeyAlgorithm = "hmac-sha384"
+	assert.Error(t, d.checkDDNS())
+	d.DDNSKeyAlgorithm = defaultTSIGAlgo
+
+	d.ddnsKeySecret = "not base64!"
+	assert.Error(t, d.checkDDNS())
+
+	d.ddnsKeySecret = ""
+	assert.Equal(t, ErrDriverDDNSOptions, d.checkDDNS())
+
+	d.DDNSServer = ""
+	assert.NoError(t, d.checkDDNS())
+}
+
+// TestDDNSKeySecret - the TSIG secret is kept in the machine secrets, not in
+// the config, and read from them once the machine is loaded again
+func TestDDNSKeySecret(t *testing.T) {
+	d := newDDNSDriver(t, "10.0.0.2")
+	defer os.RemoveAll(d.StorePath)
+	assert.NoError(t, d.saveDDNSSecret())
+
+	config, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(config), testDDNSSecret)
+
+	loaded := &Driver{}
+	assert.NoError(t, json.Unmarshal(config, loaded))
+	assert.Empty(t, loaded.ddnsKeySecret)
+	secret, _ := base64.StdEncoding.DecodeString(testDDNSSecret)
+	assert.Equal(t, secret, loaded.ddnsKey().Secret)
+}
+
+func TestRegisterDNS(t *testing.T) {
+	s := newDDNSServerStub(t)
+	defer s.Close()
+	d := newDDNSDriver(t, s.conn.LocalAddr().String())
+	defer os.RemoveAll(d.StorePath)
+
+	assert.NoError(t, d.registerDNS("10.0.1.20"))
+	updates := s.received()
+	if assert.Len(t, updates, 2) {
+		assert.Equal(t, "example.com.", updates[0].Zone)
+		assert.Equal(t, []dnsRR{
+			{Name: "node1.example.com.", Type: dnsTypeA, Class: dnsClassANY, Data: []byte{}},
+			{Name: "node1.example.com.", Type: dnsTypeAAAA, Class: dnsClassANY, Data: []byte{}},
+			{Name: "node1.example.com.", Type: dnsTypeA, Class: dnsClassIN, TTL: defaultDDNSTTL, Data: []byte{10, 0, 1, 20}},
+		}, updates[0].Updates)
+		assert.Equal(t, "1.0.10.in-addr.arpa.", updates[1].Zone)
+		target, _ := packName("node1.example.com.")
+		assert.Equal(t, dnsRR{Name: "20.1.0.10.in-addr.arpa.", Type: dnsTypePTR, Class: dnsClassIN, TTL: defaultDDNSTTL, Data: target},
+			updates[1].Updates[1])
+	}
+	assert.Equal(t, ddnsState{Name: "node1.example.com.", Address: "10.0.1.20"}, d.readDDNSState())
+
+	// same address, nothing sent
+	assert.NoError(t, d.registerDNS("10.0.1.20"))
+	assert.Len(t, s.received(), 2)
+
+	// new address, the old PTR record goes
+	assert.NoError(t, d.registerDNS("10.0.1.21"))
+	updates = s.received()
+	if assert.Len(t, updates, 5) {
+		assert.Equal(t, []dnsRR{deleteRRset("20.1.0.10.in-addr.arpa.", dnsTypePTR)}, clearData(updates[3].Updates))
+		assert.Equal(t, "21.1.0.10.in-addr.arpa.", updates[4].Updates[1].Name)
+	}
+
+	assert.NoError(t, d.unregisterDNS())
+	updates = s.received()
+	if assert.Len(t, updates, 7) {
+		assert.Equal(t, []dnsRR{deleteRRset("node1.example.com.", dnsTypeA), deleteRRset("node1.example.com.", dnsTypeAAAA)},
+			clearData(updates[5].Updates))
+		assert.Equal(t, []dnsRR{deleteRRset("21.1.0.10.in-addr.arpa.", dnsTypePTR)}, clearData(updates[6].Updates))
+	}
+	assert.Equal(t, ddnsState{}, d.readDDNSState())
+
+	// nothing registered, nothing sent
+	assert.NoError(t, d.unregisterDNS())
+	assert.Len(t, s.received(), 7)
+}
+
+func TestRegisterDNSRefused(t *testing.T) {
+	s := newDDNSServerStub(t)
+	defer s.Close()
+	d := newDDNSDriver(t, s.conn.LocalAddr().String())
+	defer os.RemoveAll(d.StorePath)
+
+	s.Lock()
+	s.refuse = "1.0.10.in-addr.arpa."
+	s.Unlock()
+	err := d.registerDNS("10.0.1.20")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "REFUSED")
+		assert.Contains(t, err.Error(), "PTR")
+	}
+	assert.Equal(t, "10.0.1.20", d.readDDNSState().Address)
+
+	d.ddnsKeySecret = base64.StdEncoding.EncodeToString([]byte("wrong"))
+	d.DNSName = "other"
+	err = d.registerDNS("10.0.1.20")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "NOTAUTH")
+	}
+}
+
+// clearData - records with empty data as nil, to compare with deleteRRset
+func clearData(rrs []dnsRR) []dnsRR {
+	out := make([]dnsRR, len(rrs))
+	for i, rr := range rrs {
+		if len(rr.Data) == 0 {
+			rr.Data = nil
+		}
+		out[i] = rr
+	}
+	return out
+}
+
+// TestTSIGKnownAnswer - an update and its answer as signed by the miekg/dns
+// library, the TSIG implementation of CoreDNS, with a fixed id and time
+func TestTSIGKnownAnswer(t *testing.T) {
+	const (
+		request = "123428000001000000020001076578616d706c6503636f6d0000060001056e6f646531076578616d706c6503636f6d00000100ff000000000000" +
+			"056e6f646531076578616d706c6503636f6d00000100010000012c0004c000020a08746573742d6b65790000fa00ff00000000003d0b686d61632d" +
+			"73686132353600000059682f00012c0020189f7990447b5c6b75352fdea4db5a0d7af7410ee4b23a77b0e938a73fa990dd123400000000"
+		answer = "1234a8000001000000000001076578616d706c6503636f6d000006000108746573742d6b65790000fa00ff00000000003d0b686d61632d7368" +
+			"61323536000000" + "59682f01012c0020f72ba9ce0efcb0999bfbc7fdc350e5e7efdc116614f9bca14d3f36aaac34c203123400000000"
+	)
+	secret, _ := base64.StdEncoding.DecodeString(testDDNSSecret)
+	key := tsigKey{Name: "test-key", Algorithm: "hmac-sha256", Secret: secret}
+	u := dnsUpdate{Zone: "example.com.", Updates: []dnsRR{
+		deleteRRset("node1.example.com.", dnsTypeA),
+		addressRR("node1.example.com.", net.ParseIP("192.0.2.10")),
+	}}
+	msg, err := u.pack(0x1234)
+	assert.NoError(t, err)
+	signed, mac, err := key.sign(msg, time.Unix(1500000000, 0), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, request, hex.EncodeToString(signed))
+
+	resp, _ := hex.DecodeString(answer)
+	assert.NoError(t, key.verify(resp, mac, time.Unix(1500000010, 0)))
+
+	// out of the fudge
+	assert.Error(t, key.verify(resp, mac, time.Unix(1500000400, 0)))
+	// another request MAC, a forged or replayed answer
+	other := append([]byte{}, mac...)
+	other[0] ^= 1
+	err = key.verify(resp, other, time.Unix(1500000010, 0))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match")
+	}
+	// the answer without its TSIG record
+	unsigned := append([]byte{}, resp[:29]...)
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+	assert.EqualError(t, key.verify(unsigned, mac, time.Unix(1500000010, 0)), "the answer is not signed")
+}
+
+// TestRegisterDNSOverTCP - a truncated udp answer sends the update again over tcp
+func TestRegisterDNSOverTCP(t *testing.T) {
+	s := newDDNSServerStub(t)
+	defer s.Close()
+	d := newDDNSDriver(t, s.conn.LocalAddr().String())
+	defer os.RemoveAll(d.StorePath)
+
+	s.Lock()
+	s.truncate = true
+	s.Unlock()
+	assert.NoError(t, d.registerDNS("10.0.1.20"))
+	assert.Len(t, s.received(), 2)
+	s.Lock()
+	assert.Equal(t, 2, s.overTCP)
+	s.Unlock()
+}
+
+// TestRegisterDNSUnsigned - an unsigned answer is not taken for a success
+func TestRegisterDNSUnsigned(t *testing.T) {
+	s := newDDNSServerStub(t)
+	defer s.Close()
+	d := newDDNSDriver(t, s.conn.LocalAddr().String())
+	defer os.RemoveAll(d.StorePath)
+
+	s.Lock()
+	s.unsigned = true
+	s.Unlock()
+	err := d.registerDNS("10.0.1.20")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the answer is not signed")
+	}
+	assert.Equal(t, ddnsState{}, d.readDDNSState())
+}
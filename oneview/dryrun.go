@@ -0,0 +1,236 @@
+package oneview
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// dry run output formats
+const (
+	dryRunFormatText = "text"
+	dryRunFormatJSON = "json"
+
+	redacted = "<redacted>"
+)
+
+// Error messages
+var (
+	ErrDriverDryRun              = errors.New("Dry run complete, no changes were made")
+	ErrDriverInvalidDryRunFormat = errors.New("Invalid option --oneview-dry-run-format, supported formats are text and json")
+)
+
+// createPlan - what Create would do for the current configuration
+type createPlan struct {
+	MachineName       string            `json:"machineName"`
+	BladeName         string            `json:"bladeName,omitempty"`
+	BladeURI          string            `json:"bladeUri,omitempty"`
+	BladeSerialNumber string            `json:"bladeSerialNumber,omitempty"`
+	ProfileName       string            `json:"profileName"`
+	ServerTemplate    string            `json:"serverTemplate"`
+	ServerTemplateURI string            `json:"serverTemplateUri,omitempty"`
+	OSBuildPlan       string            `json:"osBuildPlan"`
+	IloUser           string            `json:"iloUser"`
+	IloPassword       string            `json:"iloPassword"`
+	CustomAttributes  map[string]string `json:"customAttributes"`
+	PublicConnection  string            `json:"publicConnection,omitempty"`
+	PublicNetwork     string            `json:"publicNetwork,omitempty"`
+	PublicSlotID      int               `json:"publicSlotId,omitempty"`
+	PublicMAC         string            `json:"publicMac"`
+	PublicInterface   string            `json:"publicInterface"`
+	URL               string            `json:"url"`
+	DNSRegistration   string            `json:"dnsRegistration,omitempty"`
+	Problems          []string          `json:"problems,omitempty"`
+}
+
+// isSecretAttribute - attribute names that hold credentials
+func isSecretAttribute(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"password", "secret", "token", "private"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue - hide credentials in an attribute value, including user info
+// embedded in urls such as proxy settings
+func redactValue(name string, value string) string {
+	if value == "" {
+		return value
+	}
+	if isSecretAttribute(name) {
+		return redacted
+	}
+	fields := strings.Fields(value)
+	for i, f := range fields {
+		prefix := ""
+		if j := strings.Index(f, "="); j > 0 && !strings.Contains(f[:j], "/") {
+			prefix, f = f[:j+1], f[j+1:]
+		}
+		if u, err := url.Parse(f); err == nil && u.User != nil {
+			u.User = url.User("REDACTED")
+			fields[i] = prefix + u.String()
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// buildCreatePlan - run the lookups Create depends on without changing anything
+func (d *Driver) buildCreatePlan(problems []string) createPlan {
+	plan := createPlan{
+		MachineName:      d.MachineName,
+		ProfileName:      d.MachineName,
+		ServerTemplate:   d.ServerTemplate,
+		OSBuildPlan:      d.OSBuildPlan,
+		IloUser:          d.IloUser,
+		IloPassword:      redacted,
+		CustomAttributes: make(map[string]string),
+		PublicConnection: d.PublicConnectionName,
+		PublicNetwork:    d.PublicNetwork,
+		PublicMAC:        macUnknown,
+		PublicInterface:  interfacePlaceholder,
+		URL:              fmt.Sprintf("tcp://<public ip>:%d", d.enginePort()),
+		Problems:         problems,
+	}
+	if d.SSHBastion != "" {
+		plan.URL = fmt.Sprintf("tcp://localhost:<port>, a local port picked at create and forwarded to the same port of <public ip> through ssh bastion %s", d.SSHBastion)
+		if d.EnginePort != engine.DefaultPort {
+			plan.URL = fmt.Sprintf("tcp://localhost:%d, forwarded to <public ip>:%d through ssh bastion %s", d.enginePort(), d.enginePort(), d.SSHBastion)
+		}
+	}
+	if d.DDNSServer != "" {
+		plan.DNSRegistration = fmt.Sprintf("%s and its PTR record on %s", d.ddnsName(), d.ddnsServer())
+	}
+	if d.IloEphemeralAccount {
+		plan.IloUser = iloAccountName(d.MachineName) + " (created for the machine)"
+	}
+	if d.PublicConnectionName == "" && d.PublicNetwork == "" {
+		plan.PublicSlotID = d.PublicSlotID
+	}
+
+	for k, v := range d.serverAttributes() {
+		plan.CustomAttributes[k] = redactValue(k, v)
+	}
+	var spec []string
+	if len(d.BondConnections) > 0 {
+		spec = append(spec, fmt.Sprintf("%s %s of %s, vlans %v, macs assigned when the profile is created",
+			bondName, d.BondMode, strings.Join(d.BondConnections, ", "), d.VLANs))
+	}
+	if d.IPv4Subnet != "" {
+		spec = append(spec, fmt.Sprintf("static address reserved from %s", d.IPv4Subnet))
+	}
+	if len(spec) > 0 {
+		plan.CustomAttributes["network_spec"] = strings.Join(spec, ", ")
+	}
+	if d.SSHPublicKey == "" {
+		plan.CustomAttributes["public_key"] = d.plannedPublicKey()
+	}
+
+	template, err := d.getServerTemplate()
+	if err != nil {
+		return plan
+	}
+	plan.ServerTemplateURI = template.URI.String()
+	plan.PublicMAC = d.plannedMAC(template)
+	plan.PublicInterface = d.plannedInterfaceName(template)
+	plan.CustomAttributes["interface"] = plan.PublicInterface
+	if hw, err := d.availableHardware(template); err == nil {
+		plan.BladeName = hw.Name
+		plan.BladeURI = hw.URI.String()
+		plan.BladeSerialNumber = hw.SerialNumber.String()
+	}
+	return plan
+}
+
+// plannedPublicKey - public key the build plan gets, read from ssh-agent or
+// the key file when they are available
+func (d *Driver) plannedPublicKey() string {
+	switch {
+	case d.usesSSHAgent():
+		signer, err := d.getAgentSigner()
+		if err != nil {
+			return fmt.Sprintf("taken from ssh-agent during create, %s", err)
+		}
+		return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey())))
+	case d.ownsSSHKey():
+		return "generated during create"
+	}
+	key, err := readPublicKey(d.GetSSHKeyPath())
+	if err != nil {
+		return fmt.Sprintf("read from %s during create, %s", d.GetSSHKeyPath(), err)
+	}
+	return strings.TrimSpace(key)
+}
+
+// text - human readable form of the plan
+func (p createPlan) text() string {
+	lines := []string{
+		fmt.Sprintf("Dry run for machine %s, no changes will be made", p.MachineName),
+		fmt.Sprintf("  blade             : %s (%s, serial %s)", p.BladeName, p.BladeURI, p.BladeSerialNumber),
+		fmt.Sprintf("  server profile    : %s", p.ProfileName),
+		fmt.Sprintf("  server template   : %s (%s)", p.ServerTemplate, p.ServerTemplateURI),
+		fmt.Sprintf("  os build plan     : %s", p.OSBuildPlan),
+		fmt.Sprintf("  ilo user          : %s", p.IloUser),
+	}
+	switch {
+	case p.PublicNetwork != "":
+		lines = append(lines, fmt.Sprintf("  public network    : %s", p.PublicNetwork))
+	case p.PublicConnection != "":
+		lines = append(lines, fmt.Sprintf("  public connection : %s", p.PublicConnection))
+	default:
+		lines = append(lines, fmt.Sprintf("  public slot id    : %d", p.PublicSlotID))
+	}
+	lines = append(lines,
+		fmt.Sprintf("  public mac        : %s", p.PublicMAC),
+		fmt.Sprintf("  public interface  : %s", p.PublicInterface),
+		fmt.Sprintf("  docker url        : %s", p.URL),
+	)
+	if p.DNSRegistration != "" {
+		lines = append(lines, fmt.Sprintf("  dns registration  : %s", p.DNSRegistration))
+	}
+	lines = append(lines, "  custom attributes :")
+
+	names := make([]string, 0, len(p.CustomAttributes))
+	for k := range p.CustomAttributes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		lines = append(lines, fmt.Sprintf("    %s = %s", k, p.CustomAttributes[k]))
+	}
+	for _, problem := range p.Problems {
+		lines = append(lines, fmt.Sprintf("  problem           : %s", problem))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dryRun - print what Create would do, always returns an error so docker-machine
+// stops before the machine is saved or created
+func (d *Driver) dryRun(problems checkErrors) error {
+	plan := d.buildCreatePlan(problems)
+	switch d.DryRunFormat {
+	case dryRunFormatJSON:
+		data, err := json.Marshal(plan)
+		if err != nil {
+			return err
+		}
+		log.Info(string(data))
+	default:
+		for _, line := range strings.Split(plan.text(), "\n") {
+			log.Info(line)
+		}
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+	return ErrDriverDryRun
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package oneview
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile - take the lock file next to path, shared with the other driver
+// processes.  Blocks until the lock is free, the lock goes away with the
+// process holding it.  Call the returned func to unlock.
+func lockFile(path string) (func(), error) {
+	return flockFile(path, syscall.LOCK_EX)
+}
+
+// tryLockFile - lockFile without waiting, false when another process or
+// request holds the lock
+func tryLockFile(path string) (func(), bool, error) {
+	unlock, err := flockFile(path, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return nil, false, nil
+	}
+	return unlock, err == nil, err
+}
+
+// flockFile - flock the lock file next to path with how
+func flockFile(path string, how int) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
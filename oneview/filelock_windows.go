@@ -0,0 +1,58 @@
+//go:build windows
+// +build windows
+
+package oneview
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// LockFileEx flags and the error of a lock held by another handle
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile - take the lock file next to path, shared with the other driver
+// processes.  Blocks until the lock is free, the lock goes away with the
+// process holding it.  Call the returned func to unlock.
+func lockFile(path string) (func(), error) {
+	return lockFileEx(path, lockfileExclusiveLock)
+}
+
+// tryLockFile - lockFile without waiting, false when another process or
+// request holds the lock
+func tryLockFile(path string) (func(), bool, error) {
+	unlock, err := lockFileEx(path, lockfileExclusiveLock|lockfileFailImmediately)
+	if err == errorLockViolation {
+		return nil, false, nil
+	}
+	return unlock, err == nil, err
+}
+
+// lockFileEx - lock the lock file next to path with flags
+func lockFileEx(path string, flags uintptr) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,245 @@
+package oneview
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	iloAccountsURI        = "/redfish/v1/AccountService/Accounts/"
+	iloAccountSecret      = "ilo-account-password"
+	iloLoginNameMaxLength = 39
+	iloPasswordLength     = 24
+	iloPasswordChars      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// ErrDriverMissingIloAdmin - no way to authenticate against iLO to create the account
+var ErrDriverMissingIloAdmin = errors.New("OneView single sign-on to iLO is not available, set --oneview-ilo-user and --oneview-ilo-password to an iLO account that can administer user accounts")
+
+// iloClient - minimal iLO REST client used to manage the machine account
+type iloClient struct {
+	Endpoint  string
+	Token     string
+	User      string
+	Password  string
+	SSLVerify bool
+	policy    retryPolicy
+}
+
+// iloAccountOem - login name and privileges of an iLO account
+type iloAccountOem struct {
+	LoginName  string          `json:"LoginName"`
+	Privileges map[string]bool `json:"Privileges"`
+}
+
+// iloAccount - iLO account resource with the privileges ICSP needs to manage
+// the blade.  iLO 4 reads the Hp OEM section and iLO 5 the Hpe one, both are
+// sent so the account works on either generation.
+type iloAccount struct {
+	UserName string `json:"UserName"`
+	Password string `json:"Password"`
+	Oem      struct {
+		Hp  *iloAccountOem `json:"Hp,omitempty"`
+		Hpe *iloAccountOem `json:"Hpe,omitempty"`
+	} `json:"Oem"`
+}
+
+// call - send a request to iLO with the retry policy, returns the response
+// headers on success
+func (c *iloClient) call(method string, path string, body interface{}) (header http.Header, err error) {
+	err = c.policy.do(method+" "+path, method != "POST", func() (err error) {
+		header, err = c.send(method, path, body)
+		return err
+	})
+	return header, err
+}
+
+// send - send one request to iLO
+func (c *iloClient) send(method string, path string, body interface{}) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("X-Auth-Token", c.Token)
+	} else {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           environmentProxy.proxy,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: !c.SSLVerify},
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, statusError{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(data)}
+	}
+	return resp.Header, nil
+}
+
+// createAccount - create an iLO account, returns the account uri
+func (c *iloClient) createAccount(user string, password string) (string, error) {
+	// ICSP logs in, boots the blade from virtual media and powers it, it
+	// does not change the iLO settings or its accounts
+	oem := &iloAccountOem{
+		LoginName: user,
+		Privileges: map[string]bool{
+			"LoginPriv":                true,
+			"RemoteConsolePriv":        true,
+			"VirtualMediaPriv":         true,
+			"VirtualPowerAndResetPriv": true,
+		},
+	}
+	account := iloAccount{UserName: user, Password: password}
+	account.Oem.Hp = oem
+	account.Oem.Hpe = oem
+	header, err := c.call("POST", iloAccountsURI, account)
+	if err != nil {
+		return "", err
+	}
+	location := header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("iLO did not return a location for account %s", user)
+	}
+	if u, err := url.Parse(location); err == nil {
+		location = u.Path
+	}
+	return location, nil
+}
+
+// deleteAccount - delete the iLO account at uri
+func (c *iloClient) deleteAccount(uri string) error {
+	_, err := c.call("DELETE", uri, nil)
+	return err
+}
+
+// randomIloPassword - random password made of characters iLO accepts
+func randomIloPassword() (string, error) {
+	password := make([]byte, iloPasswordLength)
+	max := big.NewInt(int64(len(iloPasswordChars)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = iloPasswordChars[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// iloAccountName - login name of the machine account, limited to what iLO
+// accepts.  Long names end with a hash of the machine name so machines that
+// share a prefix get accounts of their own.
+func iloAccountName(machineName string) string {
+	name := "dm-" + machineName
+	if len(name) > iloLoginNameMaxLength {
+		sum := sha256.Sum256([]byte(machineName))
+		suffix := "-" + hex.EncodeToString(sum[:])[:8]
+		name = name[:iloLoginNameMaxLength-len(suffix)] + suffix
+	}
+	return name
+}
+
+// getIloClient - connect to the blade iLO, preferring a session from the
+// OneView single sign-on and falling back to --oneview-ilo-user
+func (d *Driver) getIloClient() (*iloClient, error) {
+	c := &iloClient{
+		Endpoint:  "https://" + hostPort(d.Hardware.GetIloIPAddress(), d.IloPort),
+		SSLVerify: d.ClientOV.SSLVerify,
+		policy:    d.retryPolicy(),
+	}
+
+	var sso struct {
+		IloSsoURL string `json:"iloSsoUrl"`
+	}
+	if err := d.ovCall(rest.GET, d.Hardware.URI.String()+"/iloSsoUrl", nil, &sso); err != nil {
+		log.Debugf("OneView single sign-on to iLO failed : %s", err)
+	} else if u, err := url.Parse(sso.IloSsoURL); err == nil {
+		for k, v := range u.Query() {
+			if strings.EqualFold(k, "sessionkey") && len(v) > 0 {
+				c.Token = v[0]
+				return c, nil
+			}
+		}
+	}
+
+	if d.IloUser == "" || d.IloPassword == "" {
+		return nil, ErrDriverMissingIloAdmin
+	}
+	c.User = d.IloUser
+	c.Password = d.IloPassword
+	return c, nil
+}
+
+// createIloAccount - create the iLO account dedicated to this machine, the
+// password is kept in the machine secrets
+func (d *Driver) createIloAccount() (user string, password string, err error) {
+	log.Infof("Creating iLO account for %s...", d.MachineName)
+	c, err := d.getIloClient()
+	if err != nil {
+		return "", "", err
+	}
+	if password, err = randomIloPassword(); err != nil {
+		return "", "", err
+	}
+	user = iloAccountName(d.MachineName)
+	uri, err := c.createAccount(user, password)
+	if err != nil {
+		return "", "", err
+	}
+	d.IloAccountUser = user
+	d.IloAccountURI = uri
+	d.IloAccountAddress = d.Hardware.GetIloIPAddress()
+	if err := d.saveSecret(iloAccountSecret, password); err != nil {
+		return "", "", err
+	}
+	log.Debugf("created iLO account %s => %s", user, uri)
+	return user, password, nil
+}
+
+// deleteIloAccount - delete the iLO account created for this machine
+func (d *Driver) deleteIloAccount() error {
+	if d.IloAccountURI == "" {
+		return nil
+	}
+	log.Infof("Deleting iLO account %s...", d.IloAccountUser)
+	c, err := d.getIloClient()
+	if err != nil {
+		return err
+	}
+	if d.IloAccountAddress != "" {
+		c.Endpoint = "https://" + hostPort(d.IloAccountAddress, d.IloPort)
+	}
+	if err := c.deleteAccount(d.IloAccountURI); err != nil {
+		return err
+	}
+	d.IloAccountURI = ""
+	return d.deleteSecret(iloAccountSecret)
+}
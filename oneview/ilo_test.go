@@ -0,0 +1,61 @@
+package oneview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIloAccount - create and delete an account against a stubbed iLO
+func TestIloAccount(t *testing.T) {
+	var created iloAccount
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "session", r.Header.Get("X-Auth-Token"))
+		switch {
+		case r.Method == "POST" && r.URL.Path == iloAccountsURI:
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Header().Set("Location", "https://ilo"+iloAccountsURI+"3/")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "DELETE" && r.URL.Path == iloAccountsURI+"3/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := &iloClient{Endpoint: server.URL, Token: "session"}
+	uri, err := c.createAccount("dm-test", "secret")
+	assert.NoError(t, err, "createAccount threw error -> %s\n", err)
+	assert.Equal(t, iloAccountsURI+"3/", uri)
+	for _, oem := range []*iloAccountOem{created.Oem.Hp, created.Oem.Hpe} {
+		if assert.NotNil(t, oem) {
+			assert.Equal(t, "dm-test", oem.LoginName)
+			assert.True(t, oem.Privileges["VirtualMediaPriv"])
+			assert.True(t, oem.Privileges["VirtualPowerAndResetPriv"])
+			assert.False(t, oem.Privileges["iLOConfigPriv"])
+			assert.False(t, oem.Privileges["UserConfigPriv"])
+		}
+	}
+
+	assert.NoError(t, c.deleteAccount(uri))
+	assert.Error(t, c.deleteAccount(iloAccountsURI+"4/"))
+}
+
+// TestIloAccountName - names and passwords stay within what iLO accepts
+func TestIloAccountName(t *testing.T) {
+	assert.Equal(t, "dm-test", iloAccountName("test"))
+	long := iloAccountName(strings.Repeat("a", 50) + "-1")
+	assert.Len(t, long, iloLoginNameMaxLength)
+	assert.NotEqual(t, long, iloAccountName(strings.Repeat("a", 50)+"-2"))
+
+	password, err := randomIloPassword()
+	assert.NoError(t, err)
+	assert.Len(t, password, iloPasswordLength)
+	other, _ := randomIloPassword()
+	assert.NotEqual(t, password, other)
+}
@@ -0,0 +1,160 @@
+package oneview
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// interfacePlaceholder - interface attribute left for the build plan when the
+// name can not be worked out
+const interfacePlaceholder = "@interface@"
+
+// macUnknown - public MAC address shown by the dry run when OneView picks it
+// from its pool during the profile assignment
+const macUnknown = "not known until the profile is assigned"
+
+// portIDPattern - OneView connection port ids, Flb 1:1-a is the FlexNIC a of
+// port 1 on the FlexLOM in slot 1, Mezz 3:2-b the FlexNIC b of port 2 on mezzanine 3
+var portIDPattern = regexp.MustCompile(`^(?i)(flb|lom|mezz)\s*(\d+):(\d+)(?:-([a-d]))?$`)
+
+// predictableName - the predictable network interface name of a port on a
+// dual port adapter.  Onboard FlexLOM ports are named eno<n> from their
+// firmware index, mezzanine ports ens<slot>f<function>.  Virtual Connect
+// presents the FlexNICs of a dual port adapter as PCI functions in the order
+// 1a, 2a, 1b, 2b, 1c, 2c, 1d, 2d, the FlexNIC enumeration of the HP Virtual
+// Connect FlexFabric cookbook, so FlexNIC f of port p is function 2f+p-1.
+func predictableName(portID string) (string, bool) {
+	m := portIDPattern.FindStringSubmatch(strings.TrimSpace(portID))
+	if m == nil {
+		return "", false
+	}
+	slot, _ := strconv.Atoi(m[2])
+	port, _ := strconv.Atoi(m[3])
+	function := 0
+	if m[4] != "" {
+		function = int(strings.ToLower(m[4])[0] - 'a')
+	}
+	if port < 1 || port > 2 {
+		return "", false
+	}
+	index := function*2 + port - 1
+	if strings.ToLower(m[1]) == "mezz" {
+		return fmt.Sprintf("ens%df%d", slot, index), true
+	}
+	return fmt.Sprintf("eno%d", index+1), true
+}
+
+// publicConnection - the profile connection of the public interface, known
+// when it is picked by network or connection name
+func publicConnection(profile ov.ServerProfile, mac string) (ov.Connection, bool) {
+	for _, c := range profile.Connections {
+		if sameMAC(c.MAC.String(), mac) {
+			return c, true
+		}
+	}
+	return ov.Connection{}, false
+}
+
+// publicInterfaceName - OS name of the public interface for the build plan,
+// from --oneview-public-interface, the interface ICSP reports for the blade
+// or, as a last resort, predicted from the port of the public connection
+func (d *Driver) publicInterfaceName() string {
+	if d.PublicInterfaceName != "" {
+		return d.PublicInterfaceName
+	}
+	if iface, ok := d.icspInterface(); ok && iface.Slot != "" {
+		log.Debugf("public interface of %s is %s from ICSP", d.MachineName, iface.Slot)
+		return iface.Slot
+	}
+	if conn, ok := publicConnection(d.Profile, d.PublicMAC); ok {
+		if name, ok := predictableName(conn.PortID); ok {
+			log.Warnf("ICSP does not list the public interface of %s, using %s predicted from port %s", d.MachineName, name, conn.PortID)
+			return name
+		}
+	}
+	log.Warnf("Unable to work out the name of the public interface of %s, use --oneview-public-interface to set it", d.MachineName)
+	return interfacePlaceholder
+}
+
+// icspInterface - the public interface ICSP lists for the blade.  Before the
+// build plan the blade is looked up by serial number, ICSP already lists the
+// interfaces of a blade it discovered, the machine server is left alone so a
+// failed create never deletes a server it did not add.
+func (d *Driver) icspInterface() (icsp.Interface, bool) {
+	server := d.Server
+	if server.MID == "" && d.Profile.SerialNumber.String() != "" {
+		found, err := d.getServerBySerialNumber(d.Profile.SerialNumber.String())
+		if err != nil {
+			log.Debugf("unable to look up %s in ICSP : %s", d.MachineName, err)
+		}
+		server = found
+	}
+	if server.MID == "" {
+		return icsp.Interface{}, false
+	}
+	if d.PublicMAC == "" {
+		iface, err := server.GetInterface(d.PublicSlotID)
+		return iface, err == nil
+	}
+	// ICSP and OneView do not write mac addresses in the same case
+	for _, iface := range server.Interfaces {
+		if sameMAC(iface.MACAddr, d.PublicMAC) {
+			return iface, true
+		}
+	}
+	return icsp.Interface{}, false
+}
+
+// plannedConnection - the template connection of the public interface, known
+// when it is picked by network or connection name
+func (d *Driver) plannedConnection(template ov.ServerProfile) (ov.Connection, bool) {
+	switch {
+	case d.PublicConnectionName != "":
+		conn, err := template.GetConnectionByName(d.PublicConnectionName)
+		return conn, err == nil
+	case d.PublicNetwork != "":
+		uri, err := d.publicNetworkURI()
+		if err != nil {
+			return ov.Connection{}, false
+		}
+		conn, err := connectionOnNetwork(template, d.PublicNetwork, uri)
+		return conn, err == nil
+	}
+	return ov.Connection{}, false
+}
+
+// plannedInterfaceName - public interface name shown by the dry run, the blade
+// is not picked yet so ICSP can not be asked, the name predicted from the
+// template connections is shown as the fallback it is
+func (d *Driver) plannedInterfaceName(template ov.ServerProfile) string {
+	if d.PublicInterfaceName != "" {
+		return d.PublicInterfaceName
+	}
+	if d.PublicConnectionName == "" && d.PublicNetwork == "" {
+		return "reported by ICSP for slot " + strconv.Itoa(d.PublicSlotID)
+	}
+	conn, ok := d.plannedConnection(template)
+	if !ok {
+		return "reported by ICSP for the public MAC address"
+	}
+	if name, ok := predictableName(conn.PortID); ok {
+		return fmt.Sprintf("reported by ICSP for the public MAC address, or %s predicted from port %s", name, conn.PortID)
+	}
+	return "reported by ICSP for the public MAC address"
+}
+
+// plannedMAC - public MAC address shown by the dry run, user defined MAC
+// addresses of the template are known, pool MAC addresses are only known
+// once OneView assigns the profile
+func (d *Driver) plannedMAC(template ov.ServerProfile) string {
+	if conn, ok := d.plannedConnection(template); ok && conn.MAC.String() != "" {
+		return conn.MAC.String()
+	}
+	return macUnknown
+}
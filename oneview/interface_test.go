@@ -0,0 +1,117 @@
+package oneview
+
+import (
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/utils"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPredictableName - names of FlexLOM and mezzanine ports, with the port
+// ids OneView reports for the connections of BladeSystem blades
+func TestPredictableName(t *testing.T) {
+	for _, c := range []struct {
+		blade  string
+		portID string
+		want   string
+	}{
+		// FlexFabric 536FLB FlexLOM, FlexNICs enumerated 1a, 2a, 1b, 2b, ...
+		{"BL460c Gen9", "Flb 1:1-a", "eno1"},
+		{"BL460c Gen9", "Flb 1:2-a", "eno2"},
+		{"BL460c Gen9", "Flb 1:1-b", "eno3"},
+		{"BL460c Gen9", "Flb 1:2-b", "eno4"},
+		{"BL460c Gen9", "Flb 1:1-d", "eno7"},
+		{"BL460c Gen9", "Flb 1:2-d", "eno8"},
+		// embedded NC553i, the same enumeration
+		{"BL460c G7", "LOM 1:1-a", "eno1"},
+		{"BL460c G7", "LOM 1:2-d", "eno8"},
+		// FlexFabric 650M mezzanine in slot 3
+		{"BL460c Gen9", "Mezz 3:1-a", "ens3f0"},
+		{"BL460c Gen9", "Mezz 3:2-a", "ens3f1"},
+		{"BL460c Gen9", "Mezz 3:2-b", "ens3f3"},
+		{"BL460c Gen9", "Mezz 3:2-d", "ens3f7"},
+		// mezzanine port without FlexNICs
+		{"BL460c Gen8", "mezz 2:1", "ens2f0"},
+	} {
+		name, ok := predictableName(c.portID)
+		assert.True(t, ok, "%s %s", c.blade, c.portID)
+		assert.Equal(t, c.want, name, "%s %s", c.blade, c.portID)
+	}
+	for _, portID := range []string{"", "Auto", "Flb 1:3-a", "Mezz 3:1-e"} {
+		_, ok := predictableName(portID)
+		assert.False(t, ok, portID)
+	}
+}
+
+// TestPublicInterfaceName - override, then the port of the public connection
+// when ICSP does not list the interface
+func TestPublicInterfaceName(t *testing.T) {
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{MachineName: "test"},
+		PublicMAC:  "00:17:A4:77:00:04",
+		Profile: ov.ServerProfile{
+			Connections: []ov.Connection{
+				{Name: "deploy", MAC: utils.Nstring("00:17:a4:77:00:02"), PortID: "Flb 1:1-a"},
+				{Name: "public", MAC: utils.Nstring("00:17:a4:77:00:04"), PortID: "Mezz 3:1-b"},
+			},
+		},
+	}
+	assert.Equal(t, "ens3f2", d.publicInterfaceName())
+
+	d.PublicInterfaceName = "bond0"
+	assert.Equal(t, "bond0", d.publicInterfaceName())
+
+	d.PublicInterfaceName = ""
+	d.PublicMAC = ""
+	assert.Equal(t, interfacePlaceholder, d.publicInterfaceName())
+}
+
+// TestICSPInterface - the interface ICSP lists for the blade, matched by
+// MAC address in any case
+func TestICSPInterface(t *testing.T) {
+	d := Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test"}}
+	d.Server = icsp.Server{MID: "2", SerialNumber: "SN1", Interfaces: []icsp.Interface{
+		{Slot: "eth0", MACAddr: "00:17:a4:77:00:02"},
+		{Slot: "eth1", MACAddr: "00:17:a4:77:00:04"},
+	}}
+	d.PublicMAC = "00:17:A4:77:00:04"
+	assert.Equal(t, "eth1", d.publicInterfaceName())
+
+	d.PublicMAC = "00:17:A4:77:00:06"
+	assert.Equal(t, interfacePlaceholder, d.publicInterfaceName())
+}
+
+// TestPublicInterfaceOrder - the interface ICSP lists wins over the name
+// predicted from the port, by MAC address or by slot id, and the override
+// wins over both
+func TestPublicInterfaceOrder(t *testing.T) {
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{MachineName: "test"},
+		PublicMAC:  "00:17:A4:77:00:04",
+		Profile: ov.ServerProfile{
+			Connections: []ov.Connection{
+				{Name: "public", MAC: utils.Nstring("00:17:a4:77:00:04"), PortID: "Mezz 3:1-b"},
+			},
+		},
+		Server: icsp.Server{MID: "2", Interfaces: []icsp.Interface{
+			{Slot: "eth0", MACAddr: "00:17:a4:77:00:02"},
+			{Slot: "eth1", MACAddr: "00:17:a4:77:00:04"},
+		}},
+	}
+	assert.Equal(t, "eth1", d.publicInterfaceName())
+
+	// no public MAC, the slot id picks the interface
+	d.PublicMAC, d.PublicSlotID = "", 0
+	assert.Equal(t, "eth0", d.publicInterfaceName())
+
+	// ICSP does not list the MAC, the prediction is the last resort
+	d.PublicMAC = "00:17:A4:77:00:04"
+	d.Server.Interfaces = d.Server.Interfaces[:1]
+	assert.Equal(t, "ens3f2", d.publicInterfaceName())
+
+	d.PublicInterfaceName = "bond0"
+	assert.Equal(t, "bond0", d.publicInterfaceName())
+}
@@ -0,0 +1,97 @@
+package oneview
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ErrDriverInterrupted - the operation was interrupted, by Ctrl-C for example
+var ErrDriverInterrupted = errors.New("Operation interrupted")
+
+const (
+	// rollbackTimeout - time the create rollback may take, less than the 10
+	// minutes the plugin waits for an interrupted create before exiting
+	rollbackTimeout = 8 * time.Minute
+)
+
+// interruption - cancellation shared by the long running driver operations
+type interruption struct {
+	init       sync.Once
+	close      sync.Once
+	ch         chan struct{}
+	operations sync.WaitGroup
+}
+
+// channel - closed once the driver is interrupted
+func (i *interruption) channel() chan struct{} {
+	i.init.Do(func() {
+		i.ch = make(chan struct{})
+	})
+	return i.ch
+}
+
+// Interrupt - stop waiting on the appliances, the running Create, Stop or
+// Remove returns ErrDriverInterrupted after cleaning up.  Called from the
+// plugin signal handler.
+func (d *Driver) Interrupt() {
+	ch := d.interrupt.channel()
+	d.interrupt.close.Do(func() {
+		log.Warnf("Interrupted, stopping %s operations", d.MachineName)
+		close(ch)
+	})
+}
+
+// isInterrupted - true once Interrupt was called
+func (d *Driver) isInterrupted() bool {
+	select {
+	case <-d.interrupt.channel():
+		return true
+	default:
+		return false
+	}
+}
+
+// interrupted - channel closed on interrupt, never closed during cleanup so
+// the cleanup itself is not cut short
+func (d *Driver) interrupted() chan struct{} {
+	if atomic.LoadInt32(&d.cleaningUp) != 0 {
+		return nil
+	}
+	return d.interrupt.channel()
+}
+
+// setCleaningUp - mark the create rollback as running, interrupts do not
+// stop its requests and waits
+func (d *Driver) setCleaningUp(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&d.cleaningUp, v)
+}
+
+// beginOperation - track a long running operation, call the returned func when done
+func (d *Driver) beginOperation() func() {
+	d.interrupt.operations.Add(1)
+	return d.interrupt.operations.Done
+}
+
+// WaitOperations - wait for running operations to finish their cleanup,
+// false when they are still running after timeout
+func (d *Driver) WaitOperations(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.interrupt.operations.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,67 @@
+package oneview
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInterrupt - interrupted phases stop waiting, cleanup phases do not
+func TestInterrupt(t *testing.T) {
+	d := Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test"}, TaskTimeout: time.Minute}
+	assert.False(t, d.isInterrupted())
+
+	done := d.beginOperation()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.Interrupt()
+	}()
+	release := make(chan struct{})
+	defer close(release)
+	err := d.runPhase("slow", func() error {
+		<-release
+		return nil
+	})
+	assert.Equal(t, ErrDriverInterrupted, err)
+	assert.True(t, d.isInterrupted())
+	// a second interrupt is harmless
+	d.Interrupt()
+
+	d.setCleaningUp(true)
+	err = d.runPhase("cleanup", func() error { return nil })
+	assert.NoError(t, err)
+
+	assert.False(t, d.WaitOperations(10*time.Millisecond))
+	done()
+	assert.True(t, d.WaitOperations(10*time.Millisecond))
+}
+
+// TestInterruptRequest - an interrupt stops the appliance request in flight,
+// requests of the cleanup still run
+func TestInterruptRequest(t *testing.T) {
+	release := make(chan struct{})
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/tasks/slow" {
+			<-release
+		}
+		fmt.Fprint(w, `{"uri": "/rest/tasks/1", "taskState": "Completed"}`)
+	})
+	defer done()
+	defer close(release)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.Interrupt()
+	}()
+	err := d.ovCall(rest.GET, "/rest/tasks/slow", nil, nil)
+	assert.Equal(t, ErrDriverInterrupted, err)
+
+	d.setCleaningUp(true)
+	err = d.ovCall(rest.GET, "/rest/tasks/1", nil, nil)
+	assert.NoError(t, err)
+}
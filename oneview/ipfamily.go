@@ -0,0 +1,117 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+)
+
+// address families for --oneview-ip-family
+const (
+	ipFamilyIPv4       = "ipv4"
+	ipFamilyIPv6       = "ipv6"
+	ipFamilyPreferIPv6 = "prefer-ipv6"
+)
+
+// parseIPFamily - value of --oneview-ip-family
+func parseIPFamily(value string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(value)); f {
+	case "":
+		return ipFamilyIPv4, nil
+	case ipFamilyIPv4, ipFamilyIPv6, ipFamilyPreferIPv6:
+		return f, nil
+	}
+	return "", fmt.Errorf("Invalid value %s for --oneview-ip-family, use ipv4, ipv6 or prefer-ipv6", value)
+}
+
+// ipFamily - configured family, machines created before the option existed
+// use ipv4
+func (d *Driver) ipFamily() string {
+	if d.IPFamily == "" {
+		return ipFamilyIPv4
+	}
+	return d.IPFamily
+}
+
+// isIPv6 - true for an ipv6 address
+func isIPv6(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() == nil
+}
+
+// usableIPv6 - a global ipv6 address, link local addresses need a zone and
+// can not be reached from another network
+func usableIPv6(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return isIPv6(ip) && !parsed.IsLinkLocalUnicast() && !parsed.IsLoopback()
+}
+
+// hostPort - host:port with ipv6 addresses in brackets
+func hostPort(host string, port int) string {
+	return net.JoinHostPort(host, fmt.Sprintf("%d", port))
+}
+
+// sshHost - host name for the docker machine ssh client, it joins host and
+// port with a plain %s:%d so ipv6 addresses are bracketed here
+func sshHost(host string) string {
+	if isIPv6(host) {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// publicInterface - the ICSP interface of the public connection, by mac when
+// known or by --oneview-public-slotid
+func (d *Driver) publicInterface() (icsp.Interface, error) {
+	if d.PublicMAC != "" {
+		return d.Server.GetInterfaceFromMac(d.PublicMAC)
+	}
+	return d.Server.GetInterface(d.PublicSlotID)
+}
+
+// icspIP - address of the public interface reported by ICSP in the
+// configured family
+func (d *Driver) icspIP() (string, error) {
+	family := d.ipFamily()
+	if family == ipFamilyIPv4 {
+		return d.Server.GetPublicIPV4()
+	}
+	iface, err := d.publicInterface()
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range iface.IPV6Addr {
+		if usableIPv6(ip) {
+			return ip, nil
+		}
+	}
+	if family == ipFamilyPreferIPv6 {
+		return d.Server.GetPublicIPV4()
+	}
+	return "", errors.New("no ipv6 address reported for the public interface")
+}
+
+// pickAddress - the address of addrs in the configured family
+func (d *Driver) pickAddress(addrs []string) (string, bool) {
+	var v4, v6 string
+	for _, a := range addrs {
+		switch {
+		case v4 == "" && net.ParseIP(a) != nil && !isIPv6(a):
+			v4 = a
+		case v6 == "" && usableIPv6(a):
+			v6 = a
+		}
+	}
+	switch d.ipFamily() {
+	case ipFamilyIPv6:
+		return v6, v6 != ""
+	case ipFamilyPreferIPv6:
+		if v6 != "" {
+			return v6, true
+		}
+	}
+	return v4, v4 != ""
+}
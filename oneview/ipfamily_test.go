@@ -0,0 +1,103 @@
+package oneview
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseIPFamily - ipv4 by default, unknown families are rejected
+func TestParseIPFamily(t *testing.T) {
+	f, err := parseIPFamily("")
+	assert.NoError(t, err)
+	assert.Equal(t, ipFamilyIPv4, f)
+	f, err = parseIPFamily("Prefer-IPv6")
+	assert.NoError(t, err)
+	assert.Equal(t, ipFamilyPreferIPv6, f)
+	_, err = parseIPFamily("ipv5")
+	assert.Error(t, err)
+}
+
+// TestHostPort - ipv6 addresses are bracketed in urls and for ssh
+func TestHostPort(t *testing.T) {
+	assert.Equal(t, "10.0.0.5:2376", hostPort("10.0.0.5", 2376))
+	assert.Equal(t, "[2001:db8::5]:2376", hostPort("2001:db8::5", 2376))
+	assert.Equal(t, "blade1.example.com:22", hostPort("blade1.example.com", 22))
+	assert.Equal(t, "10.0.0.5", sshHost("10.0.0.5"))
+	assert.Equal(t, "[2001:db8::5]", sshHost("2001:db8::5"))
+}
+
+// TestPickAddress - addresses are chosen by family, link local ones skipped
+func TestPickAddress(t *testing.T) {
+	addrs := []string{"fe80::1", "2001:db8::5", "10.0.0.5"}
+	d := &Driver{}
+	ip, ok := d.pickAddress(addrs)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.5", ip)
+
+	d.IPFamily = ipFamilyIPv6
+	ip, ok = d.pickAddress(addrs)
+	assert.True(t, ok)
+	assert.Equal(t, "2001:db8::5", ip)
+	_, ok = d.pickAddress([]string{"fe80::1", "10.0.0.5"})
+	assert.False(t, ok)
+
+	d.IPFamily = ipFamilyPreferIPv6
+	ip, ok = d.pickAddress([]string{"fe80::1", "10.0.0.5"})
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.5", ip)
+}
+
+// TestICSPIPv6 - ipv6 only fails without an ipv6 address on the blade
+func TestICSPIPv6(t *testing.T) {
+	d := &Driver{IPFamily: ipFamilyIPv6}
+	_, err := d.icspIP()
+	assert.Error(t, err)
+}
+
+// TestSSHHostnameIPv6 - the ssh hostname of an ipv6 machine can be dialed the
+// way the docker-machine ssh client joins it, host:port to the port
+func TestSSHHostnameIPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no ipv6 loopback : %s", err)
+	}
+	defer l.Close()
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	dir, err := ioutil.TempDir("", "oneview-ipv6")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "node1", StorePath: dir}}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "node1"), 0700))
+	data, err := json.Marshal(bladeCache{
+		ProfileURI:  "/rest/server-profiles/1",
+		HardwareURI: "/rest/server-hardware/1",
+		ServerMID:   "1",
+		PublicIP:    "::1",
+		Expires:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(d.ResolveStorePath(bladeCacheFile), data, 0600))
+
+	host, err := d.GetSSHHostname()
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]", host)
+	conn, err := net.Dial("tcp", host+":"+strconv.Itoa(l.Addr().(*net.TCPAddr).Port))
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+}
@@ -0,0 +1,245 @@
+package oneview
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ip discovery strategies for --oneview-ip-source
+const (
+	ipSourceICSP   = "icsp"
+	ipSourceStatic = "static"
+	ipSourceDHCP   = "dhcp"
+	ipSourceDNS    = "dns"
+	ipSourceLast   = "last"
+
+	defaultIPSource = ipSourceICSP + "," + ipSourceLast
+	dhcpAPITimeout  = 10 * time.Second
+)
+
+// Error messages
+var (
+	ErrDriverMissingStaticIP   = errors.New("Missing option --oneview-static-ip, needed by the static ip source")
+	ErrDriverMissingDHCPLeases = errors.New("Missing option --oneview-dhcp-leases, needed by the dhcp ip source")
+)
+
+// parseIPSources - the strategies of --oneview-ip-source, in order
+func parseIPSources(value string) ([]string, error) {
+	var sources []string
+	for _, s := range strings.Split(value, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "":
+			continue
+		case ipSourceICSP, ipSourceStatic, ipSourceDHCP, ipSourceDNS, ipSourceLast:
+			sources = append(sources, s)
+		default:
+			return nil, fmt.Errorf("Invalid ip source %s in --oneview-ip-source, use a list of icsp, static, dhcp, dns and last", s)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("Option --oneview-ip-source needs at least one of icsp, static, dhcp, dns and last")
+	}
+	return sources, nil
+}
+
+// checkIPSources - options the configured strategies depend on
+func (d *Driver) checkIPSources() error {
+	for _, s := range d.IPSources {
+		switch {
+		case s == ipSourceStatic && d.StaticIP == "" && d.IPv4Subnet == "":
+			return ErrDriverMissingStaticIP
+		case s == ipSourceStatic && d.StaticIP != "" && net.ParseIP(d.StaticIP) == nil:
+			return fmt.Errorf("Invalid address %s in --oneview-static-ip", d.StaticIP)
+		case s == ipSourceDHCP && d.DHCPLeases == "":
+			return ErrDriverMissingDHCPLeases
+		}
+	}
+	return nil
+}
+
+// ipSources - configured strategies, machines created before the option
+// existed only know icsp
+func (d *Driver) ipSources() []string {
+	if len(d.IPSources) == 0 {
+		return []string{ipSourceICSP}
+	}
+	return d.IPSources
+}
+
+// discoverIP - try the strategies in order, the first address found wins
+func (d *Driver) discoverIP() (string, error) {
+	var failures []string
+	for _, source := range d.ipSources() {
+		ip, err := d.ipFrom(source)
+		if err == nil && ip == "" {
+			err = errors.New("no address")
+		}
+		if err != nil {
+			log.Debugf("ip source %s failed for %s : %s", source, d.MachineName, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", source, err))
+			continue
+		}
+		log.Debugf("ip of %s from %s => %s", d.MachineName, source, ip)
+		return ip, nil
+	}
+	return "", fmt.Errorf("Unable to find the ip address of %s, %s", d.MachineName, strings.Join(failures, "; "))
+}
+
+// ipFrom - the address given by one strategy
+func (d *Driver) ipFrom(source string) (string, error) {
+	switch source {
+	case ipSourceICSP:
+		if err := d.getBlade(); err != nil {
+			return "", err
+		}
+		return d.icspIP()
+	case ipSourceStatic:
+		return d.StaticIP, nil
+	case ipSourceDHCP:
+		if d.PublicMAC == "" {
+			return "", errors.New("the public mac address of the machine is not known")
+		}
+		if strings.HasPrefix(d.DHCPLeases, "http://") || strings.HasPrefix(d.DHCPLeases, "https://") {
+			return dhcpAPILease(d.DHCPLeases, d.PublicMAC, d.ClientOV != nil && d.ClientOV.SSLVerify)
+		}
+		return dhcpFileLease(d.DHCPLeases, d.PublicMAC)
+	case ipSourceDNS:
+		return d.dnsLookup(d.dnsName())
+	case ipSourceLast:
+		return d.IPAddress, nil
+	}
+	return "", fmt.Errorf("unknown ip source %s", source)
+}
+
+// dnsName - host name looked up by the dns strategy
+func (d *Driver) dnsName() string {
+	if d.DNSName != "" {
+		return d.DNSName
+	}
+	return d.MachineName
+}
+
+// dnsLookup - address of name in the configured family
+func (d *Driver) dnsLookup(name string) (string, error) {
+	addrs, err := net.LookupHost(name)
+	if err != nil {
+		return "", err
+	}
+	if ip, ok := d.pickAddress(addrs); ok {
+		return ip, nil
+	}
+	return "", fmt.Errorf("no %s address for %s", d.ipFamily(), name)
+}
+
+// sameMAC - compare mac addresses written in any case or separator
+func sameMAC(a string, b string) bool {
+	norm := func(s string) string {
+		return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(s))
+	}
+	return a != "" && norm(a) == norm(b)
+}
+
+// dhcpFileLease - address leased to mac in an ISC dhcpd or dnsmasq lease file
+func dhcpFileLease(path string, mac string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseLeases(f, mac)
+}
+
+// parseLeases - the last lease of mac, dhcpd appends newer leases at the end
+func parseLeases(r io.Reader, mac string) (string, error) {
+	var (
+		found   string
+		current string
+		active  = true
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";"))
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "lease" && fields[2] == "{":
+			// isc dhcpd block start
+			current, active = fields[1], true
+		case current != "" && len(fields) >= 3 && fields[0] == "binding" && fields[1] == "state":
+			active = fields[2] == "active"
+		case current != "" && len(fields) >= 3 && fields[0] == "hardware" && fields[1] == "ethernet":
+			if sameMAC(fields[2], mac) && active {
+				found = current
+			}
+		case line == "}":
+			current = ""
+		case current == "" && len(fields) >= 3 && sameMAC(fields[1], mac) && net.ParseIP(fields[2]) != nil:
+			// dnsmasq : expiry mac ip hostname client-id
+			found = fields[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("no lease for %s", mac)
+	}
+	return found, nil
+}
+
+// dhcpAPILease - address of mac from a lease api, {mac} in the url is replaced
+// by the address, the answer is plain text or json with an ip field
+func dhcpAPILease(endpoint string, mac string, sslVerify bool) (string, error) {
+	if strings.Contains(endpoint, "{mac}") {
+		endpoint = strings.Replace(endpoint, "{mac}", mac, -1)
+	} else {
+		sep := "?"
+		if strings.Contains(endpoint, "?") {
+			sep = "&"
+		}
+		endpoint += sep + "mac=" + mac
+	}
+	client := &http.Client{
+		Timeout: dhcpAPITimeout,
+		Transport: &http.Transport{
+			Proxy:           environmentProxy.proxy,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !sslVerify},
+		},
+	}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("lease api answered %s", resp.Status)
+	}
+
+	var lease struct {
+		IP string `json:"ip"`
+	}
+	ip := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &lease); err == nil {
+		ip = lease.IP
+	}
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("lease api answered %q, not an ip address", ip)
+	}
+	return ip, nil
+}
@@ -0,0 +1,121 @@
+package oneview
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseIPSources - strategies are validated and kept in order
+func TestParseIPSources(t *testing.T) {
+	sources, err := parseIPSources("dhcp, Static,last")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ipSourceDHCP, ipSourceStatic, ipSourceLast}, sources)
+
+	_, err = parseIPSources("icsp,arp")
+	assert.Error(t, err)
+	_, err = parseIPSources(" , ")
+	assert.Error(t, err)
+
+	d := &Driver{IPSources: []string{ipSourceStatic}}
+	assert.Equal(t, ErrDriverMissingStaticIP, d.checkIPSources())
+	d.StaticIP = "10.0.0.300"
+	assert.Error(t, d.checkIPSources())
+	d.StaticIP = "10.0.0.30"
+	assert.NoError(t, d.checkIPSources())
+	d.IPSources = []string{ipSourceDHCP}
+	assert.Equal(t, ErrDriverMissingDHCPLeases, d.checkIPSources())
+}
+
+// TestParseLeases - isc dhcpd and dnsmasq lease files
+func TestParseLeases(t *testing.T) {
+	isc := `lease 10.0.0.5 {
+  starts 3 2016/06/01 10:00:00;
+  binding state active;
+  hardware ethernet 00:17:A4:77:00:02;
+}
+lease 10.0.0.6 {
+  binding state free;
+  hardware ethernet 00:17:a4:77:00:02;
+}
+lease 10.0.0.7 {
+  binding state active;
+  hardware ethernet 00:17:a4:77:00:03;
+}
+`
+	ip, err := parseLeases(strings.NewReader(isc), "00:17:a4:77:00:02")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip)
+
+	dnsmasq := "1465000000 00:17:a4:77:00:02 10.0.1.5 blade1 *\n1465000001 00:17:a4:77:00:03 10.0.1.6 blade2 *\n"
+	ip, err = parseLeases(strings.NewReader(dnsmasq), "00-17-A4-77-00-03")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.1.6", ip)
+
+	_, err = parseLeases(strings.NewReader(dnsmasq), "00:17:a4:77:00:09")
+	assert.Error(t, err)
+}
+
+// TestDHCPAPILease - the lease api answers json or plain text
+func TestDHCPAPILease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/leases/00:17:a4:77:00:02":
+			w.Write([]byte(`{"ip": "10.0.2.5", "mac": "00:17:a4:77:00:02"}`))
+		case "/lease":
+			if r.URL.Query().Get("mac") == "00:17:a4:77:00:03" {
+				w.Write([]byte("10.0.2.6\n"))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ip, err := dhcpAPILease(server.URL+"/leases/{mac}", "00:17:a4:77:00:02", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.2.5", ip)
+	ip, err = dhcpAPILease(server.URL+"/lease", "00:17:a4:77:00:03", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.2.6", ip)
+	_, err = dhcpAPILease(server.URL+"/lease", "00:17:a4:77:00:09", false)
+	assert.Error(t, err)
+}
+
+// TestDiscoverIP - strategies are tried in order until one finds an address
+func TestDiscoverIP(t *testing.T) {
+	leases, err := ioutil.TempFile("", "leases")
+	assert.NoError(t, err)
+	defer os.Remove(leases.Name())
+	leases.WriteString("1465000000 00:17:a4:77:00:02 10.0.1.5 blade1 *\n")
+	leases.Close()
+
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{MachineName: "test", IPAddress: "10.0.0.9"},
+		IPSources:  []string{ipSourceDHCP, ipSourceLast},
+		DHCPLeases: leases.Name(),
+	}
+	// no public mac known yet, falls back to the last address
+	ip, err := d.discoverIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.9", ip)
+
+	d.PublicMAC = "00:17:a4:77:00:02"
+	ip, err = d.discoverIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.1.5", ip)
+
+	d.IPSources = []string{ipSourceStatic, ipSourceLast}
+	d.IPAddress = ""
+	_, err = d.discoverIP()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "static: no address; last: no address")
+}
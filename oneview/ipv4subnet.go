@@ -0,0 +1,213 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ipv4SubnetsURI - OneView 3.0 ipv4 subnets, each with its address ranges
+const ipv4SubnetsURI = "/rest/id-pools/ipv4/subnets"
+
+// Error messages
+var (
+	ErrDriverIPv4SubnetVersion   = errors.New("Option --oneview-ipv4-subnet needs OneView API version 300 or later")
+	ErrDriverIPv4SubnetInterface = errors.New("Option --oneview-ipv4-subnet needs the public interface picked by --oneview-public-network, --oneview-public-connection-name or a bond")
+	ErrDriverIPv4SubnetVLAN      = errors.New("Option --oneview-ipv4-subnet can not be used with --oneview-vlan-ids, the vlan interfaces are configured by dhcp")
+	ErrDriverIPv4SubnetFamily    = errors.New("Option --oneview-ipv4-subnet can not be used with --oneview-ip-family ipv6")
+	ErrDriverIPv4SubnetStatic    = errors.New("Options --oneview-ipv4-subnet and --oneview-static-ip can not be used together")
+)
+
+// restCall - signature of ovCall, lets the subnet helpers run against any server
+type restCall func(rest.Method, string, interface{}, interface{}) error
+
+// ipv4Subnet - a OneView ipv4 subnet
+type ipv4Subnet struct {
+	Name       string   `json:"name"`
+	URI        string   `json:"uri"`
+	NetworkID  string   `json:"networkId"`
+	SubnetMask string   `json:"subnetmask"`
+	Gateway    string   `json:"gateway"`
+	DNSServers []string `json:"dnsServers"`
+	Domain     string   `json:"domain"`
+	RangeURIs  []string `json:"rangeUris"`
+}
+
+// ipv4Range - an address range of a subnet
+type ipv4Range struct {
+	Name         string `json:"name"`
+	URI          string `json:"uri"`
+	Enabled      bool   `json:"enabled"`
+	StartAddress string `json:"startAddress"`
+	EndAddress   string `json:"endAddress"`
+}
+
+// idList - body and answer of the range allocator and collector
+type idList struct {
+	Count  int      `json:"count,omitempty"`
+	IDList []string `json:"idList,omitempty"`
+}
+
+// staticAddress - static configuration of the public interface in the
+// network_spec attribute
+type staticAddress struct {
+	MAC     string
+	Address string
+	Prefix  int
+	Gateway string
+	DNS     []string
+	Domain  string
+}
+
+// checkIPv4Subnet - options --oneview-ipv4-subnet works with
+func (d *Driver) checkIPv4Subnet() error {
+	switch {
+	case d.IPv4Subnet == "":
+		return nil
+	case d.PublicNetwork == "" && d.PublicConnectionName == "" && len(d.BondConnections) == 0:
+		return ErrDriverIPv4SubnetInterface
+	case len(d.VLANs) > 0:
+		return ErrDriverIPv4SubnetVLAN
+	case d.IPFamily == ipFamilyIPv6:
+		return ErrDriverIPv4SubnetFamily
+	case d.StaticIP != "":
+		return ErrDriverIPv4SubnetStatic
+	}
+	return nil
+}
+
+// findIPv4Ranges - the subnet named or numbered name with its ranges, or the
+// range named name with its subnet
+func findIPv4Ranges(call restCall, name string) (ipv4Subnet, []ipv4Range, error) {
+	var subnets []ipv4Subnet
+	if err := listAll(call, ipv4SubnetsURI, &subnets); err != nil {
+		return ipv4Subnet{}, nil, err
+	}
+	getRanges := func(s ipv4Subnet) ([]ipv4Range, error) {
+		ranges := make([]ipv4Range, 0, len(s.RangeURIs))
+		for _, uri := range s.RangeURIs {
+			var r ipv4Range
+			if err := call(rest.GET, uri, nil, &r); err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+		}
+		return ranges, nil
+	}
+
+	for _, s := range subnets {
+		if s.Name == name || s.NetworkID == name {
+			ranges, err := getRanges(s)
+			return s, ranges, err
+		}
+	}
+	for _, s := range subnets {
+		ranges, err := getRanges(s)
+		if err != nil {
+			return ipv4Subnet{}, nil, err
+		}
+		for _, r := range ranges {
+			if r.Name == name {
+				return s, []ipv4Range{r}, nil
+			}
+		}
+	}
+	return ipv4Subnet{}, nil, fmt.Errorf("No ipv4 subnet or range named %s from --oneview-ipv4-subnet found in OneView", name)
+}
+
+// allocateIPv4 - take one address from the first range that has one
+func allocateIPv4(call restCall, ranges []ipv4Range) (string, string, error) {
+	var failures []string
+	for _, r := range ranges {
+		if !r.Enabled {
+			continue
+		}
+		var ids idList
+		if err := call(rest.PUT, r.URI+"/allocator", idList{Count: 1}, &ids); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", r.Name, err))
+			continue
+		}
+		if len(ids.IDList) == 0 {
+			failures = append(failures, fmt.Sprintf("%s: no address left", r.Name))
+			continue
+		}
+		return ids.IDList[0], r.URI, nil
+	}
+	if len(failures) == 0 {
+		return "", "", errors.New("no enabled address range")
+	}
+	return "", "", errors.New(strings.Join(failures, "; "))
+}
+
+// releaseIPv4 - give an address back to its range
+func releaseIPv4(call restCall, rangeURI string, ip string) error {
+	return call(rest.PUT, rangeURI+"/collector", idList{IDList: []string{ip}}, nil)
+}
+
+// maskPrefix - prefix length of a dotted netmask
+func maskPrefix(mask string) (int, error) {
+	ip := net.ParseIP(mask).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("invalid subnet mask %s", mask)
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return 0, fmt.Errorf("invalid subnet mask %s", mask)
+	}
+	return ones, nil
+}
+
+// reserveIPv4 - allocate the machine address from --oneview-ipv4-subnet, it
+// becomes the first ip source
+func (d *Driver) reserveIPv4() (*staticAddress, error) {
+	if !d.ovFeatures().IPv4Subnets {
+		return nil, ErrDriverIPv4SubnetVersion
+	}
+	subnet, ranges, err := findIPv4Ranges(d.ovCall, d.IPv4Subnet)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := maskPrefix(subnet.SubnetMask)
+	if err != nil {
+		return nil, err
+	}
+	ip, rangeURI, err := allocateIPv4(d.ovCallOnce, ranges)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to allocate an address from %s : %s", d.IPv4Subnet, err)
+	}
+	log.Infof("Reserved address %s for %s from %s", ip, d.MachineName, d.IPv4Subnet)
+	d.ReservedIP, d.ReservedRangeURI = ip, rangeURI
+	d.StaticIP = ip
+	if d.ipSources()[0] != ipSourceStatic {
+		d.IPSources = append([]string{ipSourceStatic}, d.ipSources()...)
+	}
+
+	static := &staticAddress{
+		Address: ip,
+		Prefix:  prefix,
+		Gateway: subnet.Gateway,
+		DNS:     subnet.DNSServers,
+		Domain:  subnet.Domain,
+	}
+	if len(d.BondConnections) == 0 {
+		static.MAC = d.PublicMAC
+	}
+	return static, nil
+}
+
+// releaseReservedIPv4 - give the machine address back to OneView
+func (d *Driver) releaseReservedIPv4() error {
+	if d.ReservedIP == "" {
+		return nil
+	}
+	if err := releaseIPv4(d.ovCall, d.ReservedRangeURI, d.ReservedIP); err != nil {
+		return err
+	}
+	log.Infof("Released address %s of %s", d.ReservedIP, d.MachineName)
+	d.ReservedIP, d.ReservedRangeURI = "", ""
+	return nil
+}
@@ -0,0 +1,145 @@
+package oneview
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+// subnetServer - stub of the OneView ipv4 subnet and range resources
+type subnetServer struct {
+	sync.Mutex
+	free      map[string][]string
+	allocated []string
+}
+
+func (s *subnetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	switch {
+	case r.Method == "GET" && r.URL.Path == ipv4SubnetsURI:
+		fmt.Fprint(w, `{"members": [
+			{"name": "mgmt", "networkId": "10.0.0.0", "uri": "/rest/id-pools/ipv4/subnets/1", "subnetmask": "255.255.255.0",
+			 "gateway": "10.0.0.1", "dnsServers": ["10.0.0.2"], "domain": "example.com",
+			 "rangeUris": ["/rest/id-pools/ipv4/ranges/1", "/rest/id-pools/ipv4/ranges/2"]}]}`)
+	case r.Method == "GET" && r.URL.Path == "/rest/id-pools/ipv4/ranges/1":
+		fmt.Fprint(w, `{"name": "docker-a", "uri": "/rest/id-pools/ipv4/ranges/1", "enabled": true}`)
+	case r.Method == "GET" && r.URL.Path == "/rest/id-pools/ipv4/ranges/2":
+		fmt.Fprint(w, `{"name": "docker-b", "uri": "/rest/id-pools/ipv4/ranges/2", "enabled": true}`)
+	case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/allocator"):
+		uri := strings.TrimSuffix(r.URL.Path, "/allocator")
+		if len(s.free[uri]) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errorCode": "NO_AVAILABLE_ID"}`)
+			return
+		}
+		ip := s.free[uri][0]
+		s.free[uri] = s.free[uri][1:]
+		s.allocated = append(s.allocated, ip)
+		json.NewEncoder(w).Encode(idList{Count: 1, IDList: []string{ip}})
+	case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/collector"):
+		var ids idList
+		json.NewDecoder(r.Body).Decode(&ids)
+		uri := strings.TrimSuffix(r.URL.Path, "/collector")
+		s.free[uri] = append(s.free[uri], ids.IDList...)
+		json.NewEncoder(w).Encode(ids)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// httpCall - a restCall against the stub server
+func httpCall(base string) restCall {
+	return func(method rest.Method, uri string, options interface{}, out interface{}) error {
+		var body []byte
+		if options != nil {
+			body, _ = json.Marshal(options)
+		}
+		req, err := http.NewRequest(method.String(), base+uri, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s %s answered %s", method, uri, resp.Status)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(data, out)
+	}
+}
+
+// TestIPv4Allocation - find the subnet, allocate from the ranges in order and
+// give the address back
+func TestIPv4Allocation(t *testing.T) {
+	stub := &subnetServer{free: map[string][]string{
+		"/rest/id-pools/ipv4/ranges/2": {"10.0.0.20", "10.0.0.21"},
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	call := httpCall(server.URL)
+
+	subnet, ranges, err := findIPv4Ranges(call, "10.0.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "mgmt", subnet.Name)
+	assert.Len(t, ranges, 2)
+
+	_, ranges, err = findIPv4Ranges(call, "docker-b")
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 1)
+
+	_, _, err = findIPv4Ranges(call, "storage")
+	assert.Error(t, err)
+
+	// the first range is exhausted
+	_, ranges, _ = findIPv4Ranges(call, "mgmt")
+	ip, rangeURI, err := allocateIPv4(call, ranges)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.20", ip)
+	assert.Equal(t, "/rest/id-pools/ipv4/ranges/2", rangeURI)
+
+	assert.NoError(t, releaseIPv4(call, rangeURI, ip))
+	assert.Equal(t, []string{"10.0.0.21", "10.0.0.20"}, stub.free[rangeURI])
+
+	stub.free = map[string][]string{}
+	_, _, err = allocateIPv4(call, ranges)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "docker-a")
+	assert.Contains(t, err.Error(), "docker-b")
+}
+
+// TestMaskPrefix - dotted netmasks to prefix lengths
+func TestMaskPrefix(t *testing.T) {
+	prefix, err := maskPrefix("255.255.252.0")
+	assert.NoError(t, err)
+	assert.Equal(t, 22, prefix)
+	_, err = maskPrefix("255.0.255.0")
+	assert.Error(t, err)
+	_, err = maskPrefix("")
+	assert.Error(t, err)
+}
+
+// TestCheckIPv4Subnet - options that do not work with a reserved address
+func TestCheckIPv4Subnet(t *testing.T) {
+	d := &Driver{IPv4Subnet: "mgmt"}
+	assert.Equal(t, ErrDriverIPv4SubnetInterface, d.checkIPv4Subnet())
+	d.PublicNetwork = "public"
+	assert.NoError(t, d.checkIPv4Subnet())
+	d.StaticIP = "10.0.0.5"
+	assert.Equal(t, ErrDriverIPv4SubnetStatic, d.checkIPv4Subnet())
+}
@@ -0,0 +1,123 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// localDomain - the appliance local user directory
+const localDomain = "LOCAL"
+
+// loginDomain - a directory the appliance accepts logins from
+type loginDomain struct {
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// loginDomainList - appliances answer with a plain list or a member list
+type loginDomainList []loginDomain
+
+// UnmarshalJSON - accept both shapes of the login domain list
+func (l *loginDomainList) UnmarshalJSON(data []byte) error {
+	var members struct {
+		Members []loginDomain `json:"members"`
+	}
+	if err := json.Unmarshal(data, &members); err == nil {
+		*l = members.Members
+		return nil
+	}
+	var domains []loginDomain
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return err
+	}
+	*l = domains
+	return nil
+}
+
+// names - domain names for error messages
+func (l loginDomainList) names() []string {
+	names := []string{localDomain}
+	for _, d := range l {
+		if !strings.EqualFold(d.Name, localDomain) {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// find - the domain matching name case insensitively, a user principal suffix
+// such as corp.example.com also matches a directory named corp
+func (l loginDomainList) find(name string) (string, bool) {
+	if strings.EqualFold(name, localDomain) {
+		return localDomain, true
+	}
+	for _, d := range l {
+		if strings.EqualFold(d.Name, name) {
+			return d.Name, true
+		}
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		for _, d := range l {
+			if strings.EqualFold(d.Name, name[:i]) {
+				return d.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isLocalDomain - true for users of the appliance local user directory
+func isLocalDomain(domain string) bool {
+	return domain == "" || strings.EqualFold(domain, localDomain)
+}
+
+// splitUserPrincipal - split a directory user given as user@domain, the
+// principal only sets the domain when no other domain than LOCAL was given
+func splitUserPrincipal(user string, domain string) (string, string) {
+	i := strings.LastIndex(user, "@")
+	if i <= 0 || i == len(user)-1 {
+		return user, domain
+	}
+	if domain != "" && !strings.EqualFold(domain, localDomain) {
+		return user, domain
+	}
+	return user[:i], user[i+1:]
+}
+
+// validateLoginDomain - check domain against the list of the appliance and
+// return the name the appliance uses for it
+func validateLoginDomain(appliance string, domain string, domains loginDomainList) (string, error) {
+	if domain == "" {
+		return localDomain, nil
+	}
+	name, ok := domains.find(domain)
+	if !ok {
+		return "", fmt.Errorf("%s login domain %q not found, available domains are: %s",
+			appliance, domain, strings.Join(domains.names(), ", "))
+	}
+	return name, nil
+}
+
+// checkLoginDomains - validate the configured domains before we attempt to
+// log in with them, the login domain list does not need a session
+func (d *Driver) checkLoginDomains() error {
+	var ovDomains, icspDomains loginDomainList
+
+	err := d.applianceGet(applianceOV, "/rest/logindomains", &ovDomains)
+	if err != nil {
+		log.Warnf("Unable to get OneView login domains, skipping domain check : %s", err)
+	} else if d.ClientOV.Domain, err = validateLoginDomain("OneView", d.ClientOV.Domain, ovDomains); err != nil {
+		return err
+	}
+
+	err = d.applianceGet(applianceICSP, "/rest/logindomains", &icspDomains)
+	if err != nil {
+		log.Warnf("Unable to get ICSP login domains, skipping domain check : %s", err)
+	} else if d.ClientICSP.Domain, err = validateLoginDomain("ICSP", d.ClientICSP.Domain, icspDomains); err != nil {
+		return err
+	}
+	return nil
+}
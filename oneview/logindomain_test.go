@@ -0,0 +1,46 @@
+package oneview
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplitUserPrincipal - user@domain sets the domain unless one was given
+func TestSplitUserPrincipal(t *testing.T) {
+	user, domain := splitUserPrincipal("docker@corp.example.com", "LOCAL")
+	assert.Equal(t, "docker", user)
+	assert.Equal(t, "corp.example.com", domain)
+
+	user, domain = splitUserPrincipal("docker@corp.example.com", "LAB")
+	assert.Equal(t, "docker@corp.example.com", user)
+	assert.Equal(t, "LAB", domain)
+
+	user, domain = splitUserPrincipal("administrator", "local")
+	assert.Equal(t, "administrator", user)
+	assert.Equal(t, "local", domain)
+}
+
+// TestValidateLoginDomain - domains are matched case insensitively
+func TestValidateLoginDomain(t *testing.T) {
+	var plain, members loginDomainList
+	assert.NoError(t, json.Unmarshal([]byte(`[{"name": "Corp"}, {"name": "LAB"}]`), &plain))
+	assert.NoError(t, json.Unmarshal([]byte(`{"members": [{"name": "Corp"}, {"name": "LAB"}]}`), &members))
+	assert.Equal(t, plain, members)
+
+	domain, err := validateLoginDomain("OneView", "corp", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "Corp", domain)
+
+	domain, err = validateLoginDomain("OneView", "corp.example.com", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "Corp", domain)
+
+	domain, err = validateLoginDomain("OneView", "local", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "LOCAL", domain)
+
+	_, err = validateLoginDomain("OneView", "dev", plain)
+	assert.EqualError(t, err, `OneView login domain "dev" not found, available domains are: LOCAL, Corp, LAB`)
+}
@@ -0,0 +1,116 @@
+package oneview
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/Sheetal-R/oneview-golang/utils"
+)
+
+// hardwareNoProfile - state of server hardware without a server profile
+const hardwareNoProfile = "NoProfileApplied"
+
+// filterQuery - query string of a OneView filter
+func filterQuery(filters ...string) string {
+	q := url.Values{}
+	for _, f := range filters {
+		q.Add("filter", f)
+	}
+	return q.Encode()
+}
+
+// getProfileByName - the server profile called name, a zero profile when
+// there is none
+func (d *Driver) getProfileByName(name string) (ov.ServerProfile, error) {
+	return d.findProfile("/rest/server-profiles", name)
+}
+
+// getProfileTemplateByName - the server profile template called name, a zero
+// profile when there is none
+func (d *Driver) getProfileTemplateByName(name string) (ov.ServerProfile, error) {
+	return d.findProfile("/rest/server-profile-templates", name)
+}
+
+// findProfile - the member of the profile or template collection called name
+func (d *Driver) findProfile(collection string, name string) (ov.ServerProfile, error) {
+	var profiles []ov.ServerProfile
+	uri := collection + "?" + filterQuery(fmt.Sprintf("name matches '%s'", name))
+	if err := listAll(d.ovCall, uri, &profiles); err != nil {
+		return ov.ServerProfile{}, err
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return ov.ServerProfile{}, nil
+}
+
+// getServerHardware - the server hardware at uri
+func (d *Driver) getServerHardware(uri utils.Nstring) (hw ov.ServerHardware, err error) {
+	err = d.ovCall(rest.GET, uri.String(), nil, &hw)
+	return hw, err
+}
+
+// getAvailableHardware - a blade of the hardware type and enclosure group
+// without a server profile
+func (d *Driver) getAvailableHardware(hardwareType utils.Nstring, group utils.Nstring) (ov.ServerHardware, error) {
+	var blades []struct {
+		ov.ServerHardware
+		State string `json:"state,omitempty"`
+	}
+	uri := "/rest/server-hardware?" + filterQuery(
+		fmt.Sprintf("serverHardwareTypeUri='%s'", hardwareType),
+		fmt.Sprintf("serverGroupUri='%s'", group),
+	) + "&sort=name:desc"
+	if err := listAll(d.ovCall, uri, &blades); err != nil {
+		return ov.ServerHardware{}, err
+	}
+	if len(blades) == 0 {
+		return ov.ServerHardware{}, fmt.Errorf("No blades are compatible with server template %s", d.ServerTemplate)
+	}
+	for _, b := range blades {
+		if b.State == hardwareNoProfile {
+			return b.ServerHardware, nil
+		}
+	}
+	return ov.ServerHardware{}, fmt.Errorf("No more blades are available for server template %s", d.ServerTemplate)
+}
+
+// getServerBySerialNumber - the ICSP server of the blade with serial, a zero
+// server when ICSP does not know the blade
+func (d *Driver) getServerBySerialNumber(serial string) (server icsp.Server, err error) {
+	err = d.retry("ICSP server lookup", true, d.libraryCall(applianceICSP, "GET", "/rest/os-deployment-servers", func() (err error) {
+		server, err = d.ClientICSP.GetServerBySerialNumber(serial)
+		return err
+	}))
+	return server, err
+}
+
+// isServerManaged - true when ICSP manages the blade with serial
+func (d *Driver) isServerManaged(serial string) (bool, error) {
+	server, err := d.getServerBySerialNumber(serial)
+	if err != nil {
+		return false, err
+	}
+	return icsp.Managed.Equal(server.OpswLifecycle), nil
+}
+
+// getPowerState - the power state of the blade, read again from OneView
+func (d *Driver) getPowerState() (ov.PowerState, error) {
+	hw, err := d.getServerHardware(d.Hardware.URI)
+	if err != nil {
+		return ov.P_UKNOWN, err
+	}
+	switch hw.PowerState {
+	case "On":
+		return ov.P_ON, nil
+	case "Off":
+		return ov.P_OFF, nil
+	default:
+		return ov.P_UKNOWN, nil
+	}
+}
@@ -3,42 +3,107 @@ package oneview
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sheetal-R/oneview-golang/icsp"
 	"github.com/Sheetal-R/oneview-golang/ov"
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 // Driver OneView driver structure
 type Driver struct {
 	*drivers.BaseDriver
-	ClientICSP           *icsp.ICSPClient
-	ClientOV             *ov.OVClient
-	IloUser              string
-	IloPassword          string
-	IloPort              int
-	OSBuildPlan          string
-	SSHUser              string
-	SSHPort              int
-	SSHPublicKey         string
-	ServerTemplate       string
-	PublicSlotID         int
-	PublicConnectionName string
-	Profile              ov.ServerProfile
-	Hardware             ov.ServerHardware
-	Server               icsp.Server
+	ClientICSP            *icsp.ICSPClient
+	ClientOV              *ov.OVClient
+	IloUser               string
+	IloPassword           string
+	IloPort               int
+	IloEphemeralAccount   bool
+	IloAccountUser        string
+	IloAccountURI         string
+	IloAccountAddress     string
+	OSBuildPlan           string
+	SSHUser               string
+	SSHPort               int
+	EnginePort            int
+	SSHPublicKey          string
+	SSHKey                string
+	SSHKeyType            string
+	SSHBastion            string
+	SSHBastionKey         string
+	SSHBastionHostKey     string
+	TunnelSSHPort         int
+	ServerTemplate        string
+	PublicSlotID          int
+	PublicConnectionName  string
+	PublicNetwork         string
+	PublicMAC             string
+	PublicInterfaceName   string
+	IPSources             []string
+	StaticIP              string
+	DHCPLeases            string
+	DNSName               string
+	IPFamily              string
+	BondConnections       []string
+	BondMode              string
+	VLANs                 []int
+	IPv4Subnet            string
+	ReservedIP            string
+	ReservedRangeURI      string
+	DDNSServer            string
+	DDNSZone              string
+	DDNSReverseZone       string
+	DDNSKeyName           string
+	DDNSKeyAlgorithm      string
+	DryRun                bool
+	DryRunFormat          string
+	CreateTimeout         time.Duration
+	TaskTimeout           time.Duration
+	PollInterval          time.Duration
+	RetryAttempts         int
+	RetryDelay            time.Duration
+	RetryMaxDelay         time.Duration
+	MaxRPS                int
+	MaxConcurrentRequests int
+	BladeCacheTTL         time.Duration
+	NoCache               bool
+	SkipRoleCheck         bool
+	APIProxy              string
+	APINoProxy            []string
+	Profile               ov.ServerProfile
+	Hardware              ov.ServerHardware
+	Server                icsp.Server
+
+	deadline       time.Time
+	phases         sync.WaitGroup
+	interrupt      interruption
+	operation      operation
+	cleaningUp     int32
+	createdProfile bool
+	bastion        bastionTunnels
+	proxyPassword  string
+	proxyOnce      sync.Once
+	ddnsKeySecret  string
+	apiClients     apiClients
+	ovResourceAPI  int
+	sessions       apiSessions
 }
 
 const (
-	driverName     = "oneview"
-	defaultTimeout = 1 * time.Second
+	driverName           = "oneview"
+	defaultCreateTimeout = 90 * time.Minute
+	defaultTaskTimeout   = 60 * time.Minute
+	defaultPollInterval  = 5 * time.Second
 )
 
 // Error messages
@@ -47,6 +112,7 @@ var (
 	ErrDriverMissingEndPointOptionICSP = errors.New("Missing option --oneview-icsp-endpoint or environment ONEVIEW_ICSP_ENDPOINT")
 	ErrDriverMissingTemplateOption     = errors.New("Missing option --oneview-server-template or environment ONEVIEW_SERVER_TEMPLATE")
 	ErrDriverMissingBuildPlanOption    = errors.New("Missing option --oneview-os-plan or ONEVIEW_OS_PLAN")
+	ErrDriverEnginePortIPv6            = errors.New("Option --oneview-engine-port can only change the port with --oneview-ip-family ipv4, docker-machine reads the engine port of ipv6 urls as 2376")
 )
 
 // NewDriver - create a OneView object driver
@@ -66,7 +132,7 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		mcnflag.StringFlag{
 			Name:   "oneview-ov-user",
-			Usage:  "User Name to OneView Server",
+			Usage:  "User Name to OneView Server, directory users can be given as user@domain",
 			Value:  "",
 			EnvVar: "ONEVIEW_OV_USER",
 		},
@@ -90,7 +156,7 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		},
 		mcnflag.StringFlag{
 			Name:   "oneview-icsp-user",
-			Usage:  "User Name to OneView Insight Controller",
+			Usage:  "User Name to OneView Insight Controller, directory users can be given as user@domain",
 			Value:  "",
 			EnvVar: "ONEVIEW_ICSP_USER",
 		},
@@ -117,6 +183,30 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "SSH private key path",
 			EnvVar: "ONEVIEW_SSLVERIFY",
 		},
+		mcnflag.StringFlag{
+			Name:   "oneview-api-proxy",
+			Usage:  "Optional http proxy, http://[user:password@]host:port, the OneView and ICSP appliances are reached through.",
+			Value:  "",
+			EnvVar: "ONEVIEW_API_PROXY",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-api-proxy-user",
+			Usage:  "User to authenticate to --oneview-api-proxy with.",
+			Value:  "",
+			EnvVar: "ONEVIEW_API_PROXY_USER",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-api-proxy-password",
+			Usage:  "Password to authenticate to --oneview-api-proxy with.",
+			Value:  "",
+			EnvVar: "ONEVIEW_API_PROXY_PASSWORD",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-api-no-proxy",
+			Usage:  "Comma separated hosts and domains reached without --oneview-api-proxy, the no_proxy of the environment is not used.",
+			Value:  "",
+			EnvVar: "ONEVIEW_API_NO_PROXY",
+		},
 		mcnflag.StringFlag{
 			Name:   "oneview-ssh-user",
 			Usage:  "OneView build plan ssh user account",
@@ -129,6 +219,42 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  22,
 			EnvVar: "ONEVIEW_SSH_PORT",
 		},
+		mcnflag.IntFlag{
+			Name:   "oneview-engine-port",
+			Usage:  "Port the docker engine listens on, passed to the build plan to open the OS firewall",
+			Value:  engine.DefaultPort,
+			EnvVar: "ONEVIEW_ENGINE_PORT",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ssh-key",
+			Usage:  "Optional existing private key to use instead of generating one, or agent[:<comment or fingerprint>] to use an ssh-agent identity.  The key is never removed by the driver.",
+			Value:  "",
+			EnvVar: "ONEVIEW_SSH_KEY",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ssh-key-type",
+			Usage:  "Type of ssh key to generate when --oneview-ssh-key is not set, one of rsa, ed25519 or ecdsa.",
+			Value:  sshKeyTypeRSA,
+			EnvVar: "ONEVIEW_SSH_KEY_TYPE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ssh-bastion",
+			Usage:  "Optional jump host, user@host[:port], the driver and docker-machine reach the machine ssh and docker ports through.",
+			Value:  "",
+			EnvVar: "ONEVIEW_SSH_BASTION",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ssh-bastion-key",
+			Usage:  "Private key to login to --oneview-ssh-bastion, or agent[:<comment or fingerprint>] to use an ssh-agent identity, defaults to agent.",
+			Value:  "",
+			EnvVar: "ONEVIEW_SSH_BASTION_KEY",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ssh-bastion-host-key",
+			Usage:  "Optional SHA256 fingerprint of the --oneview-ssh-bastion host key, by default the key must be in ~/.ssh/known_hosts.",
+			Value:  "",
+			EnvVar: "ONEVIEW_SSH_BASTION_HOST_KEY",
+		},
 		mcnflag.StringFlag{
 			Name:   "oneview-server-template",
 			Usage:  "OneView server template to use for blade provisioning, see OneView Server Template for setup.",
@@ -159,6 +285,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  443,
 			EnvVar: "ONEVIEW_ILO_PORT",
 		},
+		mcnflag.BoolFlag{
+			Name:   "oneview-ilo-ephemeral-account",
+			Usage:  "Create a dedicated ILO account with a random password for this machine, removed with the machine.  Uses OneView single sign-on to ILO, or the ILO user when single sign-on is not available.",
+			EnvVar: "ONEVIEW_ILO_EPHEMERAL_ACCOUNT",
+		},
 		mcnflag.IntFlag{
 			Name:   "oneview-public-slotid",
 			Usage:  "Optional slot id of the public interface to use for connecting with docker.",
@@ -171,19 +302,206 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  "",
 			EnvVar: "ONEVIEW_PUBLIC_CONNECTION_NAME",
 		},
+		mcnflag.StringFlag{
+			Name:   "oneview-public-network",
+			Usage:  "Optional OneView ethernet network or network set name, the profile connection attached to it is the public interface.  Overrides slotid option.",
+			Value:  "",
+			EnvVar: "ONEVIEW_PUBLIC_NETWORK",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-public-interface",
+			Usage:  "Optional OS name of the public interface passed to the build plan, for example ens3f0.  Worked out from the public connection port when empty.",
+			Value:  "",
+			EnvVar: "ONEVIEW_PUBLIC_INTERFACE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ip-source",
+			Usage:  "Ordered list of ways to find the machine ip address : icsp, static, dhcp, dns and last (the last known address).",
+			Value:  defaultIPSource,
+			EnvVar: "ONEVIEW_IP_SOURCE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-static-ip",
+			Usage:  "Address of the machine for the static ip source.",
+			Value:  "",
+			EnvVar: "ONEVIEW_STATIC_IP",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-dhcp-leases",
+			Usage:  "ISC dhcpd or dnsmasq lease file, or http(s) url of a lease api, used by the dhcp ip source to find the address leased to the public mac.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DHCP_LEASES",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-dns-name",
+			Usage:  "Host name resolved by the dns ip source, defaults to the machine name.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DNS_NAME",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ip-family",
+			Usage:  "Address family of the machine ip : ipv4, ipv6 or prefer-ipv6 (ipv6 when the machine has one, else ipv4).",
+			Value:  ipFamilyIPv4,
+			EnvVar: "ONEVIEW_IP_FAMILY",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-bond-connections",
+			Usage:  "Comma separated names of two or more server profile connections the build plan bonds together.",
+			Value:  "",
+			EnvVar: "ONEVIEW_BOND_CONNECTIONS",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-bond-mode",
+			Usage:  "Mode of the bond : active-backup or lacp.",
+			Value:  bondModeActiveBackup,
+			EnvVar: "ONEVIEW_BOND_MODE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-vlan-ids",
+			Usage:  "Comma separated vlan ids tagged on the bond, each gets an interface configured by dhcp.",
+			Value:  "",
+			EnvVar: "ONEVIEW_VLAN_IDS",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ipv4-subnet",
+			Usage:  "Optional OneView ipv4 subnet or address range, by name or network id, the machine address is reserved from it and set statically.",
+			Value:  "",
+			EnvVar: "ONEVIEW_IPV4_SUBNET",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-server",
+			Usage:  "Optional dns server, host or host:port, the machine name and address are registered on with dynamic updates.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DDNS_SERVER",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-zone",
+			Usage:  "Zone the machine name is registered in.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DDNS_ZONE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-reverse-zone",
+			Usage:  "Zone of the PTR record, defaults to the /24 in-addr.arpa or /64 ip6.arpa zone of the address.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DDNS_REVERSE_ZONE",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-key-name",
+			Usage:  "Name of the TSIG key signing the dns updates.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DDNS_KEY_NAME",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-key-secret",
+			Usage:  "Base64 secret of the TSIG key.",
+			Value:  "",
+			EnvVar: "ONEVIEW_DDNS_KEY_SECRET",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-ddns-key-algorithm",
+			Usage:  "Algorithm of the TSIG key : hmac-md5, hmac-sha1, hmac-sha256 or hmac-sha512.",
+			Value:  defaultTSIGAlgo,
+			EnvVar: "ONEVIEW_DDNS_KEY_ALGORITHM",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-create-timeout",
+			Usage:  "Overall time allowed for create, such as 90m.",
+			Value:  defaultCreateTimeout.String(),
+			EnvVar: "ONEVIEW_CREATE_TIMEOUT",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-task-timeout",
+			Usage:  "Time allowed for each OneView task or ICSP job, such as 60m.",
+			Value:  defaultTaskTimeout.String(),
+			EnvVar: "ONEVIEW_TASK_TIMEOUT",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-poll-interval",
+			Usage:  "Time between checks of a running OneView task or ICSP job, such as 5s.",
+			Value:  defaultPollInterval.String(),
+			EnvVar: "ONEVIEW_POLL_INTERVAL",
+		},
+		mcnflag.IntFlag{
+			Name:   "oneview-retry-attempts",
+			Usage:  "Number of attempts for OneView, ICSP and ILO calls that fail with a transient error, 0 disables retries.",
+			Value:  defaultRetryAttempts,
+			EnvVar: "ONEVIEW_RETRY_ATTEMPTS",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-retry-delay",
+			Usage:  "Initial wait before retrying a failed call, doubled on each retry, such as 2s.",
+			Value:  defaultRetryDelay.String(),
+			EnvVar: "ONEVIEW_RETRY_DELAY",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-retry-max-delay",
+			Usage:  "Longest wait between two retries of a failed call, such as 30s.",
+			Value:  defaultRetryMaxDelay.String(),
+			EnvVar: "ONEVIEW_RETRY_MAX_DELAY",
+		},
+		mcnflag.IntFlag{
+			Name:   "oneview-max-rps",
+			Usage:  "Maximum requests per second to each OneView and ICSP appliance, shared by all machines created from this host, 0 for no limit.",
+			Value:  defaultMaxRPS,
+			EnvVar: "ONEVIEW_MAX_RPS",
+		},
+		mcnflag.IntFlag{
+			Name:   "oneview-max-concurrent-requests",
+			Usage:  "Maximum requests in flight to each OneView and ICSP appliance, shared by all machines created from this host, 0 for no limit.",
+			Value:  defaultMaxConcurrentRequests,
+			EnvVar: "ONEVIEW_MAX_CONCURRENT_REQUESTS",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-cache-ttl",
+			Usage:  "Time the profile, blade, ICSP server and public ip of the machine are cached for status commands, such as 60s.",
+			Value:  defaultBladeCacheTTL.String(),
+			EnvVar: "ONEVIEW_CACHE_TTL",
+		},
+		mcnflag.BoolFlag{
+			Name:   "oneview-no-cache",
+			Usage:  "Always look up the machine on the appliances instead of using the cache.",
+			EnvVar: "ONEVIEW_NO_CACHE",
+		},
+		mcnflag.BoolFlag{
+			Name:   "oneview-skip-role-check",
+			Usage:  "Create with an account whose roles can not be read, such as a directory account, with a warning instead of an error.",
+			EnvVar: "ONEVIEW_SKIP_ROLE_CHECK",
+		},
+		mcnflag.BoolFlag{
+			Name:   "oneview-dry-run",
+			Usage:  "Run all lookups and checks for create, print the plan and exit without making changes.",
+			EnvVar: "ONEVIEW_DRY_RUN",
+		},
+		mcnflag.StringFlag{
+			Name:   "oneview-dry-run-format",
+			Usage:  "Format of the dry run plan, text or json.",
+			Value:  dryRunFormatText,
+			EnvVar: "ONEVIEW_DRY_RUN_FORMAT",
+		},
 	}
 }
 
 // DriverName - get the name of the driver
 func (d *Driver) DriverName() string {
-	log.Debug("DriverName...%s", driverName)
+	log.Debugf("DriverName...%s", driverName)
 	return driverName
 }
 
-// GetSSHHostname - gets the hostname that docker-machine connects to
+// GetSSHHostname - gets the hostname that docker-machine connects to, the
+// local end of the ssh tunnel when the machine is behind a bastion.  The
+// docker-machine ssh client joins it to the port with %s:%d, ipv6 addresses
+// are bracketed.
 func (d *Driver) GetSSHHostname() (string, error) {
 	log.Debug("GetSSHHostname...")
-	return d.GetIP()
+	if d.SSHBastion != "" {
+		return tunnelHost, nil
+	}
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return sshHost(ip), nil
 }
 
 // GetSSHUsername - gets the ssh user that will be connected to
@@ -196,36 +514,133 @@ func (d *Driver) GetSSHUsername() string {
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	log.Debug("SetConfigFromFlags...")
 
-	d.ClientICSP = d.ClientICSP.NewICSPClient(flags.String("oneview-icsp-user"),
+	// directory users can be given as user@domain
+	icspUser, icspDomain := splitUserPrincipal(flags.String("oneview-icsp-user"), flags.String("oneview-icsp-domain"))
+	ovUser, ovDomain := splitUserPrincipal(flags.String("oneview-ov-user"), flags.String("oneview-ov-domain"))
+
+	if err := d.setAPIProxyConfig(flags.String("oneview-api-proxy"), flags.String("oneview-api-proxy-user"),
+		flags.String("oneview-api-proxy-password"), flags.String("oneview-api-no-proxy")); err != nil {
+		return err
+	}
+
+	d.ClientICSP = d.ClientICSP.NewICSPClient(icspUser,
 		flags.String("oneview-icsp-password"),
-		flags.String("oneview-icsp-domain"),
+		icspDomain,
 		flags.String("oneview-icsp-endpoint"),
 		flags.Bool("oneview-sslverify"),
 		1)
 
-	d.ClientOV = d.ClientOV.NewOVClient(flags.String("oneview-ov-user"),
+	d.ClientOV = d.ClientOV.NewOVClient(ovUser,
 		flags.String("oneview-ov-password"),
-		flags.String("oneview-ov-domain"),
+		ovDomain,
 		flags.String("oneview-ov-endpoint"),
 		flags.Bool("oneview-sslverify"),
 		1)
 
-	d.ClientICSP.RefreshVersion()
-	d.ClientOV.RefreshVersion()
-
 	d.IloUser = flags.String("oneview-ilo-user")
 	d.IloPassword = flags.String("oneview-ilo-password")
 	d.IloPort = flags.Int("oneview-ilo-port")
+	d.IloEphemeralAccount = flags.Bool("oneview-ilo-ephemeral-account")
 
 	d.PublicSlotID = flags.Int("oneview-public-slotid")
 	d.PublicConnectionName = flags.String("oneview-public-connection-name")
+	d.PublicNetwork = flags.String("oneview-public-network")
+	d.PublicInterfaceName = flags.String("oneview-public-interface")
+	if d.PublicNetwork != "" && d.PublicConnectionName != "" {
+		return ErrDriverPublicInterfaceOptions
+	}
+
+	var err error
+	if d.IPSources, err = parseIPSources(flags.String("oneview-ip-source")); err != nil {
+		return err
+	}
+	d.StaticIP = flags.String("oneview-static-ip")
+	d.DHCPLeases = flags.String("oneview-dhcp-leases")
+	d.DNSName = flags.String("oneview-dns-name")
+	d.IPv4Subnet = flags.String("oneview-ipv4-subnet")
+	if d.IPFamily, err = parseIPFamily(flags.String("oneview-ip-family")); err != nil {
+		return err
+	}
+	if err := d.checkIPSources(); err != nil {
+		return err
+	}
+
+	if d.BondConnections, err = parseBondConnections(flags.String("oneview-bond-connections")); err != nil {
+		return err
+	}
+	if d.BondMode, err = parseBondMode(flags.String("oneview-bond-mode")); err != nil {
+		return err
+	}
+	if d.VLANs, err = parseVLANs(flags.String("oneview-vlan-ids")); err != nil {
+		return err
+	}
+	if len(d.VLANs) > 0 && len(d.BondConnections) == 0 {
+		return ErrDriverVLANWithoutBond
+	}
+	if err := d.checkIPv4Subnet(); err != nil {
+		return err
+	}
+
+	d.DDNSServer = flags.String("oneview-ddns-server")
+	d.DDNSZone = flags.String("oneview-ddns-zone")
+	d.DDNSReverseZone = flags.String("oneview-ddns-reverse-zone")
+	d.DDNSKeyName = flags.String("oneview-ddns-key-name")
+	d.ddnsKeySecret = flags.String("oneview-ddns-key-secret")
+	d.DDNSKeyAlgorithm = strings.ToLower(flags.String("oneview-ddns-key-algorithm"))
+	if err := d.checkDDNS(); err != nil {
+		return err
+	}
 
 	d.SSHUser = flags.String("oneview-ssh-user")
 	d.SSHPort = flags.Int("oneview-ssh-port")
+	d.EnginePort = flags.Int("oneview-engine-port")
+	if d.EnginePort < 1 || d.EnginePort > 65535 {
+		return fmt.Errorf("Invalid port %d in --oneview-engine-port", d.EnginePort)
+	}
+	if err := d.setSSHKeyConfig(flags.String("oneview-ssh-key"), flags.String("oneview-ssh-key-type")); err != nil {
+		return err
+	}
+	if err := d.setBastionConfig(flags.String("oneview-ssh-bastion"), flags.String("oneview-ssh-bastion-key"), flags.String("oneview-ssh-bastion-host-key")); err != nil {
+		return err
+	}
+	// behind a bastion the docker url is on localhost, whatever the family
+	if d.EnginePort != engine.DefaultPort && d.IPFamily != ipFamilyIPv4 && d.SSHBastion == "" {
+		return ErrDriverEnginePortIPv6
+	}
 
 	d.ServerTemplate = flags.String("oneview-server-template")
 	d.OSBuildPlan = flags.String("oneview-os-plan")
 
+	if d.CreateTimeout, err = parseDuration("oneview-create-timeout", flags.String("oneview-create-timeout"), defaultCreateTimeout); err != nil {
+		return err
+	}
+	if d.TaskTimeout, err = parseDuration("oneview-task-timeout", flags.String("oneview-task-timeout"), defaultTaskTimeout); err != nil {
+		return err
+	}
+	if d.PollInterval, err = parseDuration("oneview-poll-interval", flags.String("oneview-poll-interval"), defaultPollInterval); err != nil {
+		return err
+	}
+	d.RetryAttempts = flags.Int("oneview-retry-attempts")
+	d.MaxRPS = flags.Int("oneview-max-rps")
+	d.MaxConcurrentRequests = flags.Int("oneview-max-concurrent-requests")
+	if d.BladeCacheTTL, err = parseDuration("oneview-cache-ttl", flags.String("oneview-cache-ttl"), defaultBladeCacheTTL); err != nil {
+		return err
+	}
+	d.NoCache = flags.Bool("oneview-no-cache")
+	d.SkipRoleCheck = flags.Bool("oneview-skip-role-check")
+	if d.RetryDelay, err = parseDuration("oneview-retry-delay", flags.String("oneview-retry-delay"), defaultRetryDelay); err != nil {
+		return err
+	}
+	if d.RetryMaxDelay, err = parseDuration("oneview-retry-max-delay", flags.String("oneview-retry-max-delay"), defaultRetryMaxDelay); err != nil {
+		return err
+	}
+
+	d.DryRun = flags.Bool("oneview-dry-run")
+	d.DryRunFormat = flags.String("oneview-dry-run-format")
+	if d.DryRunFormat != dryRunFormatText && d.DryRunFormat != dryRunFormatJSON {
+		return ErrDriverInvalidDryRunFormat
+	}
+
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
@@ -249,34 +664,60 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		return ErrDriverMissingBuildPlanOption
 	}
 
-	return nil
+	// pick the api versions to use with each appliance
+	return d.negotiateAPIVersions()
 }
 
 // PreCreateCheck - pre create check
 func (d *Driver) PreCreateCheck() (err error) {
+	defer d.auditOperation("pre-create check", &err)()
 	log.Debug("PreCreateCheck...")
-	// verify you can connect to ov
-	ovVersion, err := d.ClientOV.GetAPIVersion()
-	if err != nil {
+	// verify you can connect to ov and icsp with supported versions
+	if err := d.negotiateAPIVersions(); err != nil {
 		return err
 	}
-	if ovVersion.CurrentVersion <= 0 {
-		return fmt.Errorf("Unable to get a valid version from OneView,  %+v\n", ovVersion)
-	}
-	// verify you can connect to icsp
-	icspVersion, err := d.ClientICSP.GetAPIVersion()
-	if err != nil {
+	// verify the login domains before logging in with them
+	if err := d.checkLoginDomains(); err != nil {
 		return err
 	}
-	if icspVersion.CurrentVersion <= 0 {
-		return fmt.Errorf("Unable to get a valid version from ICsp,  %+v\n", icspVersion)
+	// verify the accounts and configuration before any hardware is allocated,
+	// every problem found is reported in one error
+	var problems checkErrors
+	problems.add(d.checkPrivileges()...)
+	problems.add(d.checkConfiguration()...)
+	if d.DryRun {
+		return d.dryRun(problems)
 	}
-	return nil
+	return problems.err()
 }
 
-// Create - create server for docker
-func (d *Driver) Create() error {
-	log.Infof("Generating SSH keys...")
+// Create - create server for docker, undoing what was created when it fails
+func (d *Driver) Create() (err error) {
+	defer d.beginOperation()()
+	defer d.auditOperation("create", &err)()
+	d.startDeadline(d.CreateTimeout)
+	defer d.clearDeadline()
+
+	err = d.create()
+	if err != nil {
+		d.rollbackCreate()
+	}
+	return err
+}
+
+// create - the create steps
+func (d *Driver) create() error {
+	if err := d.saveAPIProxySecret(); err != nil {
+		return err
+	}
+	if err := d.saveDDNSSecret(); err != nil {
+		return err
+	}
+	// the engine port behind a bastion is picked before the build plan opens it
+	if err := d.allocateTunnelPorts(); err != nil {
+		return err
+	}
+	log.Infof("Setting up SSH keys...")
 	if err := d.createKeyPair(); err != nil {
 		return fmt.Errorf("unable to create key pair: %s", err)
 	}
@@ -287,7 +728,8 @@ func (d *Driver) Create() error {
 
 	log.Debugf("***> CreateMachine")
 	// create d.Hardware and d.Profile
-	if err := d.ClientOV.CreateMachine(d.MachineName, d.ServerTemplate); err != nil {
+	d.createdProfile = true
+	if err := d.createProfile(); err != nil {
 		return err
 	}
 
@@ -296,48 +738,54 @@ func (d *Driver) Create() error {
 	}
 
 	// power off let customization bring the server online
-	if err := d.Hardware.PowerOff(); err != nil {
+	if err := d.powerOff(); err != nil {
 		return err
 	}
 
+	// use a dedicated ilo account for this machine when asked to
+	iloUser, iloPassword := d.IloUser, d.IloPassword
+	if d.IloEphemeralAccount {
+		var err error
+		if iloUser, iloPassword, err = d.createIloAccount(); err != nil {
+			return err
+		}
+	}
+
 	// add the server to icsp, TestCreateServer
 	// apply a build plan, TestApplyDeploymentJobs
 	var sp *icsp.CustomServerAttributes
 	sp = sp.New()
-	sp.Set("docker_user", d.SSHUser)
-	sp.Set("public_key", d.SSHPublicKey)
-	// TODO: make a util for this
-	if len(os.Getenv("proxy_enable")) > 0 {
-		sp.Set("proxy_enable", os.Getenv("proxy_enable"))
-	} else {
-		sp.Set("proxy_enable", "false")
+	for k, v := range d.serverAttributes() {
+		sp.Set(k, v)
 	}
 
-	strProxy := os.Getenv("proxy_config")
-	sp.Set("proxy_config", strProxy)
-
-	sp.Set("docker_hostname", d.MachineName+"-@server_name@")
-
-	sp.Set("interface", "@interface@") // this is populated later
-
 	// Get the mac address for public Connection on server profile
-	var publicmac string
-	if d.PublicConnectionName != "" {
-		conn, err := d.Profile.GetConnectionByName(d.PublicConnectionName)
-		if err != nil {
+	publicmac, err := d.publicMAC()
+	if err != nil {
+		return err
+	}
+	d.PublicMAC = publicmac
+
+	// static address, bond and vlan configuration for the build plan
+	var static *staticAddress
+	if d.IPv4Subnet != "" {
+		if static, err = d.reserveIPv4(); err != nil {
 			return err
 		}
-		publicmac = conn.MAC.String()
-	} else {
-		publicmac = ""
 	}
+	spec, err := d.networkSpec(static)
+	if err != nil {
+		return err
+	}
+	sp.Set("network_spec", spec)
+	sp.Set("interface", d.publicInterfaceName())
 
 	// arguments for customize server
 	cs := icsp.CustomizeServer{
 		HostName:         d.MachineName,                   // machine-rack-enclosure-bay
 		SerialNumber:     d.Profile.SerialNumber.String(), // get it
-		ILoUser:          d.IloUser,
-		IloPassword:      d.IloPassword,
+		ILoUser:          iloUser,
+		IloPassword:      iloPassword,
 		IloIPAddress:     d.Hardware.GetIloIPAddress(), // MpIpAddress for v1
 		IloPort:          d.IloPort,
 		OSBuildPlan:      d.OSBuildPlan,  // name of the OS build plan
@@ -346,31 +794,47 @@ func (d *Driver) Create() error {
 		ServerProperties: sp,
 	}
 	// create d.Server and apply a build plan and configure the custom attributes
-	if err := d.ClientICSP.CustomizeServer(cs); err != nil {
-                log.Infof("Inside oneview.go , %s.",err)
+	// not retried either, the server may be registered or the job started
+	// when a later request of the call fails
+	if err := d.runPhase("ICSP OS build plan deployment", d.libraryCall(applianceICSP, "POST", "/rest/os-deployment-jobs", func() error {
+		return d.ClientICSP.CustomizeServer(cs)
+	})); err != nil {
+		log.Infof("OS build plan deployment failed : %s", err)
 		return err
 	}
 
+	// keep the mac of the public interface picked by slot id, the dhcp ip
+	// source looks it up
+	if d.PublicMAC == "" {
+		if err := d.getBlade(); err == nil {
+			if iface, err := d.Server.GetInterface(d.PublicSlotID); err == nil {
+				d.PublicMAC = iface.MACAddr
+			}
+		}
+	}
+
 	ip, err := d.GetIP()
 	if err != nil {
 		return err
 	}
 	d.IPAddress = ip
 
-	// use ssh to set keys, and test ssh
-	sshClient, err := d.getLocalSSHClient()
-	if err != nil {
-		return err
+	// behind a bastion docker-machine provisions through the local ports
+	if d.SSHBastion != "" {
+		if _, _, err := d.startTunnels(ip); err != nil {
+			return err
+		}
 	}
 
-	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	// use ssh to set keys, and test ssh
+	sshClient, err := d.getLocalSSHClient()
 	if err != nil {
 		return err
 	}
 
 	if out, err := sshClient.Output(fmt.Sprintf(
 		"printf '%%s' '%s' | tee /home/%s/.ssh/authorized_keys",
-		string(pubKey),
+		d.SSHPublicKey,
 		d.GetSSHUsername(),
 	)); err != nil {
 		log.Error(out)
@@ -382,14 +846,61 @@ func (d *Driver) Create() error {
 	return nil
 }
 
+// rollbackCreate - remove what a failed or interrupted create left on the
+// appliances, the machine keys stay until docker-machine rm
+func (d *Driver) rollbackCreate() {
+	if !d.createdProfile {
+		return
+	}
+	d.setCleaningUp(true)
+	defer d.setCleaningUp(false)
+	defer closeAll(d)
+
+	log.Warnf("Create of %s failed, removing the resources it created...", d.MachineName)
+	d.startDeadline(rollbackTimeout)
+	if !d.waitPhases() {
+		log.Warnf("An appliance call of the create of %s is still running, cleaning up anyway", d.MachineName)
+	}
+	d.invalidateCaches()
+	if err := d.getBlade(); err != nil {
+		log.Warnf("Unable to find the machine for cleanup : %s", err)
+	}
+	if d.Server.MID != "" {
+		if err := d.deleteServer(); err != nil {
+			log.Warnf("Unable to delete the server from icsp : %s", err)
+		}
+	}
+	if err := d.deleteIloAccount(); err != nil {
+		log.Warnf("Unable to delete ilo account %s : %s", d.IloAccountUser, err)
+	}
+	if !d.Profile.URI.IsNil() {
+		if err := d.deleteProfile(); err != nil {
+			log.Warnf("Unable to delete server profile %s, remove it from OneView : %s", d.MachineName, err)
+		}
+	}
+	if err := d.releaseReservedIPv4(); err != nil {
+		log.Warnf("Unable to release address %s, release it in OneView : %s", d.ReservedIP, err)
+	}
+	if err := d.unregisterDNS(); err != nil {
+		log.Warnf("Unable to remove %s from dns : %s", d.MachineName, err)
+	}
+	d.createdProfile = false
+}
+
 // closeAll - cleanup sessions on the OV and ICSP appliances
 func closeAll(d *Driver) {
-	err := d.ClientOV.SessionLogout()
-	if err != nil {
+	if err := d.logout(applianceOV); err != nil {
 		log.Warnf("OV Session Logout : %s", err)
 	}
-	err = d.ClientICSP.SessionLogout()
-	if err != nil {
+	if err := d.logout(applianceICSP); err != nil {
+		log.Warnf("ICSP Session Logout : %s", err)
+	}
+	// sessions the library calls logged in with
+	d.applyAPIProxy()
+	if err := d.ClientOV.SessionLogout(); err != nil {
+		log.Warnf("OV Session Logout : %s", err)
+	}
+	if err := d.ClientICSP.SessionLogout(); err != nil {
 		log.Warnf("ICSP Session Logout : %s", err)
 	}
 }
@@ -397,55 +908,65 @@ func closeAll(d *Driver) {
 // GetURL - get docker url
 func (d *Driver) GetURL() (string, error) {
 	log.Debug("GetURL...")
+	if d.SSHBastion != "" {
+		return d.bastionURL(), nil
+	}
 	ip, err := d.GetIP()
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return "tcp://" + hostPort(ip, d.enginePort()), nil
 }
 
-// GetIP - get server host or ip address
-// TODO: we need to get ip of server from icsp or ov??
-// currently the only way i can see to get this is with sudo ifconfig|grep inet
-func (d *Driver) GetIP() (string, error) {
+// enginePort - port of the docker engine, machines created before the option
+// existed use the docker default
+func (d *Driver) enginePort() int {
+	if d.EnginePort == 0 {
+		return engine.DefaultPort
+	}
+	return d.EnginePort
+}
+
+// GetIP - get server host or ip address, trying the --oneview-ip-source
+// strategies in order
+func (d *Driver) GetIP() (ip string, err error) {
+	defer d.auditOperation("ip", &err)()
 	log.Debug("GetIP...")
-	// get the blade for this driver
-	if err := d.getBlade(); err != nil {
-		return "", err
+	// the cached ip comes from icsp
+	if d.ipSources()[0] == ipSourceICSP {
+		if ip := d.cachedIP(); ip != "" {
+			return ip, nil
+		}
 	}
-	sPublicIPv4, err := d.Server.GetPublicIPV4()
-	if err != nil {
+	if ip, err = d.discoverIP(); err != nil {
 		return "", err
 	}
-	if sPublicIPv4 == "" {
-		return "", fmt.Errorf("IP address is not set")
+	// keep the dns name of the machine on its current address
+	if err := d.registerDNS(ip); err != nil {
+		log.Warnf("Unable to register %s in dns : %s", d.MachineName, err)
 	}
-	return sPublicIPv4, nil
+	return ip, nil
 }
 
 // GetState - get the running state of the target machine
-func (d *Driver) GetState() (state.State, error) {
+func (d *Driver) GetState() (st state.State, err error) {
+	defer d.auditOperation("state", &err)()
 	log.Debug("GetState...")
 
+	// many machines listed together share one snapshot of the appliances
+	if st, ok := d.snapshotState(); ok {
+		return st, nil
+	}
+
 	// get the blade for this driver
 	if err := d.getBlade(); err != nil {
 		return state.Error, err
 	}
-	if icsp.Provisioning.Equal(d.Server.OpswLifecycle) {
-		return state.Starting, nil
-	}
-	if icsp.Unprovisioned.Equal(d.Server.OpswLifecycle) ||
-		icsp.PreUnProvisioned.Equal(d.Server.OpswLifecycle) {
-		return state.Stopping, nil
-	}
-	if icsp.Deactivated.Equal(d.Server.OpswLifecycle) {
-		return state.Stopped, nil
-	}
-	if icsp.ProvisionedFailed.Equal(d.Server.OpswLifecycle) {
-		return state.Error, nil
+	if st, ok := lifecycleState(d.Server.OpswLifecycle); ok {
+		return st, nil
 	}
 	// use power state to determine status
-	ps, err := d.Hardware.GetPowerState()
+	ps, err := d.getPowerState()
 	if err != nil {
 		return state.Error, err
 	}
@@ -463,8 +984,10 @@ func (d *Driver) GetState() (state.State, error) {
 }
 
 // Start - start the docker machine target
-func (d *Driver) Start() error {
+func (d *Driver) Start() (err error) {
+	defer d.auditOperation("start", &err)()
 	log.Infof("Starting ... %s", d.MachineName)
+	d.invalidateCaches()
 
 	// get the blade for this driver
 	if err := d.getBlade(); err != nil {
@@ -472,11 +995,11 @@ func (d *Driver) Start() error {
 	}
 
 	// power on the server, and leave it in that state
-	if err := d.Hardware.PowerOn(); err != nil {
+	if err := d.powerOn(); err != nil {
 		return err
 	}
 	// implement icsp check for is in maintenance mode or started
-	isManaged, err := d.ClientICSP.IsServerManaged(d.Hardware.SerialNumber.String())
+	isManaged, err := d.isServerManaged(d.Hardware.SerialNumber.String())
 	if err != nil {
 		return err
 	}
@@ -487,9 +1010,12 @@ func (d *Driver) Start() error {
 }
 
 // Stop - stop the docker machine target
-func (d *Driver) Stop() error {
+func (d *Driver) Stop() (err error) {
+	defer d.beginOperation()()
+	defer d.auditOperation("stop", &err)()
 	log.Debug("Stop...")
 	log.Infof("Stop ... %s", d.MachineName)
+	d.invalidateCaches()
 	// gracefully attempt to stop the os
 
 	if _, err := drivers.RunSSHCommandFromDriver(d, "sudo shutdown -P now"); err != nil {
@@ -502,7 +1028,7 @@ func (d *Driver) Stop() error {
 	}
 
 	// power on the server, and leave it in that state
-	if err := d.Hardware.PowerOff(); err != nil {
+	if err := d.powerOff(); err != nil {
 		return err
 	}
 	// cleanup
@@ -512,8 +1038,11 @@ func (d *Driver) Stop() error {
 
 // Remove - remove the docker machine target
 //    Should remove the ICSP provisioned plan and the Server Profile from OV
-func (d *Driver) Remove() error {
+func (d *Driver) Remove() (err error) {
+	defer d.beginOperation()()
+	defer d.auditOperation("remove", &err)()
 	log.Debug("Remove...")
+	d.invalidateCaches()
 	// remove the ssh keys
 	if err := d.deleteKeyPair(); err != nil {
 		return err
@@ -525,26 +1054,31 @@ func (d *Driver) Remove() error {
 		return err
 	}
 	// destroy the server in icsp
-	isDeleted, err := d.ClientICSP.DeleteServer(d.Server.MID)
-	if err != nil {
+	if err := d.deleteServer(); err != nil {
 		return err
 	}
-	if !isDeleted {
-		return fmt.Errorf("Unable to delete the server from icsp : %s, %s", d.MachineName, d.Server.MID)
+	// icsp no longer manages the blade, drop the machine ilo account
+	if err := d.deleteIloAccount(); err != nil {
+		log.Warnf("Unable to delete ilo account %s : %s", d.IloAccountUser, err)
 	}
 	// delete the server profile in ov : TestDeleteProfile
-	t, err := d.ClientOV.SubmitDeleteProfile(d.Profile)
-	err = t.Wait()
-	if err != nil {
+	if err := d.deleteProfile(); err != nil {
 		return err
 	}
+	if err := d.releaseReservedIPv4(); err != nil {
+		log.Warnf("Unable to release address %s, release it in OneView : %s", d.ReservedIP, err)
+	}
+	if err := d.unregisterDNS(); err != nil {
+		log.Warnf("Unable to remove %s from dns : %s", d.MachineName, err)
+	}
 	// cleanup
 	defer closeAll(d)
 	return nil
 }
 
 // Restart - restart the target machine
-func (d *Driver) Restart() error {
+func (d *Driver) Restart() (err error) {
+	defer d.auditOperation("restart", &err)()
 	log.Debug("Restarting...")
 	if err := d.Stop(); err != nil {
 		return err
@@ -567,11 +1101,47 @@ func (d *Driver) publicSSHKeyPath() string {
 
 // /////////  HELPLERS /////////////
 
-func (d *Driver) getBlade() (err error) {
+// serverAttributes - custom attributes passed to the ICSP build plan
+func (d *Driver) serverAttributes() map[string]string {
+	attributes := map[string]string{
+		"docker_user":     d.SSHUser,
+		"public_key":      d.SSHPublicKey,
+		"proxy_enable":    "false",
+		"proxy_config":    os.Getenv("proxy_config"),
+		"docker_hostname": d.MachineName + "-@server_name@",
+		"interface":       interfacePlaceholder, // resolved once the profile exists
+		"docker_port":     strconv.Itoa(d.enginePort()),
+		"network_spec":    noNetworkSpec, // bonds are resolved from the profile later
+	}
+	if len(os.Getenv("proxy_enable")) > 0 {
+		attributes["proxy_enable"] = os.Getenv("proxy_enable")
+	}
+	return attributes
+}
+
+// getBlade - find the profile, hardware and icsp server of the machine,
+// from their cached uris when the cache is still valid
+func (d *Driver) getBlade() error {
 	log.Debug("In getBlade()")
+	if c, ok := d.readBladeCache(); ok {
+		err := d.getCachedBlade(c)
+		if err == nil {
+			return nil
+		}
+		log.Debugf("blade cache is stale, looking up %s : %s", d.MachineName, err)
+		d.invalidateCaches()
+	}
+	if err := d.lookupBlade(); err != nil {
+		return err
+	}
+	d.saveBladeCache()
+	return nil
+}
 
-	d.Profile, err = d.ClientOV.GetProfileByName(d.MachineName)
-	if err != nil {
+// lookupBlade - search the appliances for the machine
+func (d *Driver) lookupBlade() (err error) {
+
+	if d.Profile, err = d.getProfileByName(d.MachineName); err != nil {
 		return err
 	}
 
@@ -584,44 +1154,72 @@ func (d *Driver) getBlade() (err error) {
 	// power on the server
 	// get the server hardware associated with that test profile
 	log.Debugf("***> GetServerHardware")
-	d.Hardware, err = d.ClientOV.GetServerHardware(d.Profile.ServerHardwareURI)
+	if d.Hardware, err = d.getServerHardware(d.Profile.ServerHardwareURI); err != nil {
+		return err
+	}
 	if d.Hardware.URI.IsNil() {
 		err = fmt.Errorf("Attempting to get machine blade information, unable to find machine: %s", d.MachineName)
 		return err
 	}
 	// get an icsp server
-	if d.Hardware.VirtualSerialNumber.IsNil() {
-		// get the server profile with SerialNumber
-		d.Server, err = d.ClientICSP.GetServerBySerialNumber(d.Hardware.SerialNumber.String())
-	} else {
+	serialNumber := d.Hardware.SerialNumber.String()
+	if !d.Hardware.VirtualSerialNumber.IsNil() {
 		// get the server profile with the VirtualSerialNumber
-		d.Server, err = d.ClientICSP.GetServerBySerialNumber(d.Hardware.VirtualSerialNumber.String())
-	}
-	if err != nil {
-		return err
+		serialNumber = d.Hardware.VirtualSerialNumber.String()
 	}
+	d.Server, err = d.getServerBySerialNumber(serialNumber)
 	return err
 }
 
-// createKeyPair - generate key files needed
+// deleteServer - remove the blade from ICSP, retried on transient errors
+func (d *Driver) deleteServer() error {
+	return d.retry("ICSP server deletion", true, func() error {
+		return d.runPhase("ICSP server deletion", d.libraryCall(applianceICSP, "DELETE", "/rest/os-deployment-servers/"+d.Server.MID, func() error {
+			isDeleted, err := d.ClientICSP.DeleteServer(d.Server.MID)
+			if err == nil && !isDeleted {
+				err = fmt.Errorf("Unable to delete the server from icsp : %s, %s", d.MachineName, d.Server.MID)
+			}
+			return err
+		}))
+	})
+}
+
+// createKeyPair - generate key files needed, or load the public key of the
+// key selected with --oneview-ssh-key
 func (d *Driver) createKeyPair() error {
+	if d.usesSSHAgent() {
+		signer, err := d.getAgentSigner()
+		if err != nil {
+			return err
+		}
+		d.SSHPublicKey = string(gossh.MarshalAuthorizedKey(signer.PublicKey()))
+		log.Debugf("using ssh-agent key => %s", d.SSHPublicKey)
+		return nil
+	}
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
-		return err
+	if d.ownsSSHKey() {
+		d.SSHKeyPath = d.ResolveStorePath(sshKeyFileName(d.SSHKeyType))
+		if err := generateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+			return err
+		}
 	}
 
-	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	publicKey, err := readPublicKey(d.GetSSHKeyPath())
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("created keys => %s", string(publicKey))
-	d.SSHPublicKey = string(publicKey)
+	log.Debugf("using keys => %s", publicKey)
+	d.SSHPublicKey = publicKey
 	return nil
 }
 
-// deleteKeyPair
+// deleteKeyPair - remove the key files, keys supplied by the user are left alone
 func (d *Driver) deleteKeyPair() error {
+	if !d.ownsSSHKey() {
+		log.Debugf("keeping user supplied ssh key %s", d.SSHKey)
+		return nil
+	}
 	if err := os.Remove(d.GetSSHKeyPath()); err != nil {
 		return err
 	}
@@ -632,11 +1230,30 @@ func (d *Driver) deleteKeyPair() error {
 }
 
 func (d *Driver) getLocalSSHClient() (ssh.Client, error) {
+	host, port, err := d.sshEndpoint(d.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if d.usesSSHAgent() {
+		signer, err := d.getAgentSigner()
+		if err != nil {
+			return nil, err
+		}
+		return &ssh.NativeClient{
+			Config: gossh.ClientConfig{
+				User: d.GetSSHUsername(),
+				Auth: []gossh.AuthMethod{gossh.PublicKeys(signer), gossh.Password("docker")},
+			},
+			Hostname: host,
+			Port:     port,
+		}, nil
+	}
+
 	sshAuth := &ssh.Auth{
 		Passwords: []string{"docker"},
 		Keys:      []string{d.GetSSHKeyPath()},
 	}
-	sshClient, err := ssh.NewNativeClient(d.GetSSHUsername(), d.IPAddress, d.SSHPort, sshAuth)
+	sshClient, err := ssh.NewNativeClient(d.GetSSHUsername(), host, port, sshAuth)
 	if err != nil {
 		return nil, err
 	}
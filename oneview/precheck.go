@@ -0,0 +1,144 @@
+package oneview
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+)
+
+// checkErrors - problems found before create, reported together so every
+// configuration error can be fixed in one go
+type checkErrors []string
+
+// add - record problems
+func (c *checkErrors) add(problems ...string) {
+	*c = append(*c, problems...)
+}
+
+// err - nil when no problems were found
+func (c checkErrors) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Unable to create the machine, fix the following problems:\n  - %s", strings.Join(c, "\n  - "))
+}
+
+// osBuildPlan - an ICSP OS build plan
+type osBuildPlan struct {
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// getServerTemplate - the template used for the machine, a server profile
+// template on OneView 2.0+ or an unassigned server profile on 1.20
+func (d *Driver) getServerTemplate() (template ov.ServerProfile, err error) {
+	if d.ovFeatures().ServerProfileTemplates {
+		if template, err = d.getProfileTemplateByName(d.ServerTemplate); err != nil {
+			return template, err
+		}
+		if template.URI.IsNil() {
+			return template, fmt.Errorf("Server profile template %s not found in OneView", d.ServerTemplate)
+		}
+		return template, nil
+	}
+
+	if template, err = d.getProfileByName(d.ServerTemplate); err != nil {
+		return template, err
+	}
+	if template.URI.IsNil() {
+		return template, fmt.Errorf("Server profile %s used as template not found in OneView", d.ServerTemplate)
+	}
+	if !template.ServerHardwareURI.IsNil() {
+		return template, fmt.Errorf("Server profile %s used as template is assigned to hardware, templates must be unassigned", d.ServerTemplate)
+	}
+	return template, nil
+}
+
+// osBuildPlanURI - uri of the OS build plan in ICSP, empty when there is none
+func (d *Driver) osBuildPlanURI() (string, error) {
+	var plans []osBuildPlan
+	if err := listAll(d.icspCall, "/rest/os-deployment-build-plans", &plans); err != nil {
+		return "", err
+	}
+	for _, p := range plans {
+		if p.Name == d.OSBuildPlan {
+			return p.URI, nil
+		}
+	}
+	return "", nil
+}
+
+// osBuildPlanExists - check the OS build plan is defined in ICSP
+func (d *Driver) osBuildPlanExists() (bool, error) {
+	uri, err := d.osBuildPlanURI()
+	return uri != "", err
+}
+
+// availableHardware - an unassigned blade the template can be applied to
+func (d *Driver) availableHardware(template ov.ServerProfile) (ov.ServerHardware, error) {
+	return d.getAvailableHardware(template.ServerHardwareTypeURI, template.EnclosureGroupURI)
+}
+
+// checkConfiguration - look up everything create needs, returns a problem for
+// each lookup that fails
+func (d *Driver) checkConfiguration() (problems []string) {
+	// the machine name must be free
+	if profile, err := d.getProfileByName(d.MachineName); err != nil {
+		problems = append(problems, fmt.Sprintf("Unable to check for an existing server profile %s : %s", d.MachineName, err))
+	} else if !profile.URI.IsNil() {
+		problems = append(problems, fmt.Sprintf("Server profile %s already exists in OneView, choose another machine name", d.MachineName))
+	}
+
+	// the template, its public connection and a blade it can be applied to
+	if template, err := d.getServerTemplate(); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		if d.PublicConnectionName != "" {
+			if conn, err := template.GetConnectionByName(d.PublicConnectionName); err != nil || conn.Name == "" {
+				problems = append(problems, fmt.Sprintf("Connection %s from --oneview-public-connection-name is not defined in template %s", d.PublicConnectionName, d.ServerTemplate))
+			}
+		}
+		if d.PublicNetwork != "" {
+			if uri, err := d.publicNetworkURI(); err != nil {
+				problems = append(problems, err.Error())
+			} else if _, err := connectionOnNetwork(template, d.PublicNetwork, uri); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		for _, name := range d.BondConnections {
+			if conn, err := template.GetConnectionByName(name); err != nil || conn.Name == "" {
+				problems = append(problems, fmt.Sprintf("Connection %s from --oneview-bond-connections is not defined in template %s", name, d.ServerTemplate))
+			}
+		}
+		if hw, err := d.availableHardware(template); err != nil || hw.URI.IsNil() {
+			problems = append(problems, fmt.Sprintf("No unassigned server hardware compatible with template %s is available", d.ServerTemplate))
+		}
+	}
+
+	// the subnet the machine address is reserved from
+	if d.IPv4Subnet != "" {
+		if !d.ovFeatures().IPv4Subnets {
+			problems = append(problems, ErrDriverIPv4SubnetVersion.Error())
+		} else if _, ranges, err := findIPv4Ranges(d.ovCall, d.IPv4Subnet); err != nil {
+			problems = append(problems, err.Error())
+		} else if len(ranges) == 0 {
+			problems = append(problems, fmt.Sprintf("Subnet %s from --oneview-ipv4-subnet has no address range", d.IPv4Subnet))
+		}
+	}
+
+	// the os build plan
+	if found, err := d.osBuildPlanExists(); err != nil {
+		problems = append(problems, fmt.Sprintf("Unable to get OS build plans from ICSP : %s", err))
+	} else if !found {
+		problems = append(problems, fmt.Sprintf("OS build plan %s from --oneview-os-plan not found in ICSP", d.OSBuildPlan))
+	}
+
+	// the jump host the machine is reached through
+	if d.SSHBastion != "" {
+		if err := d.connectBastion(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
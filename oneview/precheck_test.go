@@ -0,0 +1,104 @@
+package oneview
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckErrors - problems are reported together
+func TestCheckErrors(t *testing.T) {
+	var problems checkErrors
+	assert.NoError(t, problems.err())
+
+	problems.add()
+	assert.NoError(t, problems.err())
+
+	problems.add("template missing", "plan missing")
+	problems.add("name taken")
+	assert.EqualError(t, problems.err(),
+		"Unable to create the machine, fix the following problems:\n  - template missing\n  - plan missing\n  - name taken")
+}
+
+// precheckAppliances - what the stub appliances hold for checkConfiguration
+type precheckAppliances struct {
+	template    bool
+	buildPlan   bool
+	freeBlade   bool
+	nameTaken   bool
+	connections string
+}
+
+// serve - answer the lookups of checkConfiguration, the build plans are
+// split over two pages
+func (a precheckAppliances) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/rest/server-profiles":
+		if a.nameTaken {
+			fmt.Fprint(w, `{"members": [{"name": "test", "uri": "/rest/server-profiles/1"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"members": []}`)
+	case r.URL.Path == "/rest/server-profile-templates":
+		if a.template {
+			fmt.Fprintf(w, `{"members": [{"name": "tmpl", "uri": "/rest/server-profile-templates/1",
+				"serverHardwareTypeUri": "/rest/server-hardware-types/1", "enclosureGroupUri": "/rest/enclosure-groups/1",
+				"connections": [%s]}]}`, a.connections)
+			return
+		}
+		fmt.Fprint(w, `{"members": []}`)
+	case r.URL.Path == "/rest/server-hardware":
+		state := "ProfileApplied"
+		if a.freeBlade {
+			state = hardwareNoProfile
+		}
+		fmt.Fprintf(w, `{"members": [{"name": "bay 1", "uri": "/rest/server-hardware/1", "state": "%s"}]}`, state)
+	case r.URL.Path == "/rest/os-deployment-build-plans" && r.URL.Query().Get("start") == "":
+		fmt.Fprint(w, `{"members": [{"name": "other", "uri": "/rest/os-deployment-build-plans/1"}],
+			"nextPageUri": "/rest/os-deployment-build-plans?start=1"}`)
+	case r.URL.Path == "/rest/os-deployment-build-plans":
+		if a.buildPlan {
+			fmt.Fprint(w, `{"members": [{"name": "plan", "uri": "/rest/os-deployment-build-plans/2"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"members": []}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// TestCheckConfiguration - each missing piece is reported as a problem
+func TestCheckConfiguration(t *testing.T) {
+	ready := precheckAppliances{template: true, buildPlan: true, freeBlade: true, connections: `{"name": "deploy"}`}
+	for _, c := range []struct {
+		name       string
+		appliances func(a *precheckAppliances)
+		connection string
+		problem    string
+	}{
+		{"ready", func(a *precheckAppliances) {}, "", ""},
+		{"missing template", func(a *precheckAppliances) { a.template = false }, "", "Server profile template tmpl not found"},
+		{"missing build plan", func(a *precheckAppliances) { a.buildPlan = false }, "", "OS build plan plan from --oneview-os-plan not found"},
+		{"missing connection", func(a *precheckAppliances) {}, "public", "Connection public from --oneview-public-connection-name is not defined"},
+		{"no free hardware", func(a *precheckAppliances) { a.freeBlade = false }, "", "No unassigned server hardware compatible with template tmpl"},
+		{"name taken", func(a *precheckAppliances) { a.nameTaken = true }, "", "Server profile test already exists"},
+	} {
+		a := ready
+		c.appliances(&a)
+		d, _, done := testApplianceDriver(t, a.serve)
+		d.ServerTemplate = "tmpl"
+		d.OSBuildPlan = "plan"
+		d.PublicConnectionName = c.connection
+
+		problems := d.checkConfiguration()
+		if c.problem == "" {
+			assert.Empty(t, problems, c.name)
+		} else if assert.Len(t, problems, 1, c.name) {
+			assert.True(t, strings.HasPrefix(problems[0], c.problem), "%s : %s", c.name, problems[0])
+		}
+		done()
+	}
+}
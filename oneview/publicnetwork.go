@@ -0,0 +1,116 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/rest"
+)
+
+// ErrDriverPublicInterfaceOptions - more than one way to pick the public interface
+var ErrDriverPublicInterfaceOptions = errors.New("Options --oneview-public-network and --oneview-public-connection-name can not be used together")
+
+// network - an ethernet network or network set of OneView
+type network struct {
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+	Type string `json:"type"`
+}
+
+// networkList - members of /rest/ethernet-networks or /rest/network-sets
+type networkList struct {
+	Members []network `json:"members"`
+}
+
+// findNetworks - ethernet networks and network sets named name
+func (d *Driver) findNetworks(name string) ([]network, error) {
+	filter := url.QueryEscape(fmt.Sprintf("name='%s'", name))
+	var found []network
+	for _, uri := range []string{"/rest/ethernet-networks", "/rest/network-sets"} {
+		var list networkList
+		if err := d.ovCall(rest.GET, uri+"?filter="+filter, nil, &list); err != nil {
+			return nil, err
+		}
+		for _, n := range list.Members {
+			if n.Name == name {
+				found = append(found, n)
+			}
+		}
+	}
+	return found, nil
+}
+
+// publicNetworkURI - uri of the network named by --oneview-public-network
+func (d *Driver) publicNetworkURI() (string, error) {
+	networks, err := d.findNetworks(d.PublicNetwork)
+	if err != nil {
+		return "", err
+	}
+	switch len(networks) {
+	case 0:
+		return "", fmt.Errorf("No ethernet network or network set named %s from --oneview-public-network found in OneView", d.PublicNetwork)
+	case 1:
+		return networks[0].URI, nil
+	}
+	found := make([]string, len(networks))
+	for i, n := range networks {
+		found[i] = fmt.Sprintf("%s (%s)", n.URI, n.Type)
+	}
+	return "", fmt.Errorf("Several networks are named %s, rename them so --oneview-public-network is unique : %s",
+		d.PublicNetwork, strings.Join(found, ", "))
+}
+
+// connectionOnNetwork - the only connection of the profile attached to the
+// network at networkURI
+func connectionOnNetwork(profile ov.ServerProfile, networkName string, networkURI string) (ov.Connection, error) {
+	var (
+		matches []ov.Connection
+		all     []string
+	)
+	for _, c := range profile.Connections {
+		if c.NetworkURI.String() == networkURI {
+			matches = append(matches, c)
+		}
+		all = append(all, fmt.Sprintf("%s (%s)", c.Name, c.NetworkURI))
+	}
+	switch len(matches) {
+	case 0:
+		return ov.Connection{}, fmt.Errorf("No connection of %s is attached to network %s, connections are : %s",
+			profile.Name, networkName, strings.Join(all, ", "))
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, c := range matches {
+		names[i] = fmt.Sprintf("%s (port %s)", c.Name, c.PortID)
+	}
+	return ov.Connection{}, fmt.Errorf("Several connections of %s are attached to network %s : %s, use --oneview-public-connection-name to pick one",
+		profile.Name, networkName, strings.Join(names, ", "))
+}
+
+// publicMAC - mac address of the public interface, empty when the interface
+// is picked by --oneview-public-slotid
+func (d *Driver) publicMAC() (string, error) {
+	switch {
+	case d.PublicNetwork != "":
+		uri, err := d.publicNetworkURI()
+		if err != nil {
+			return "", err
+		}
+		conn, err := connectionOnNetwork(d.Profile, d.PublicNetwork, uri)
+		if err != nil {
+			return "", err
+		}
+		return conn.MAC.String(), nil
+	case d.PublicConnectionName != "":
+		conn, err := d.Profile.GetConnectionByName(d.PublicConnectionName)
+		if err != nil {
+			return "", err
+		}
+		return conn.MAC.String(), nil
+	}
+	return "", nil
+}
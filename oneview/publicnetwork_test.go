@@ -0,0 +1,35 @@
+package oneview
+
+import (
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnectionOnNetwork - the public connection is the only one on the network
+func TestConnectionOnNetwork(t *testing.T) {
+	profile := ov.ServerProfile{
+		Name: "test",
+		Connections: []ov.Connection{
+			{ID: 1, Name: "mgmt", PortID: "Flb 1:1-a", NetworkURI: utils.Nstring("/rest/ethernet-networks/mgmt"), MAC: utils.Nstring("00:00:00:00:00:01")},
+			{ID: 2, Name: "public", PortID: "Flb 1:1-b", NetworkURI: utils.Nstring("/rest/network-sets/public"), MAC: utils.Nstring("00:00:00:00:00:02")},
+			{ID: 3, Name: "data-a", PortID: "Flb 1:2-a", NetworkURI: utils.Nstring("/rest/ethernet-networks/data"), MAC: utils.Nstring("00:00:00:00:00:03")},
+			{ID: 4, Name: "data-b", PortID: "Flb 1:2-b", NetworkURI: utils.Nstring("/rest/ethernet-networks/data"), MAC: utils.Nstring("00:00:00:00:00:04")},
+		},
+	}
+
+	conn, err := connectionOnNetwork(profile, "public", "/rest/network-sets/public")
+	assert.NoError(t, err)
+	assert.Equal(t, "00:00:00:00:00:02", conn.MAC.String())
+
+	_, err = connectionOnNetwork(profile, "prod", "/rest/ethernet-networks/prod")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No connection of test is attached to network prod")
+	assert.Contains(t, err.Error(), "mgmt (/rest/ethernet-networks/mgmt)")
+
+	_, err = connectionOnNetwork(profile, "data", "/rest/ethernet-networks/data")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "data-a (port Flb 1:2-a), data-b (port Flb 1:2-b)")
+}
@@ -0,0 +1,205 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// request limit defaults, shared by every driver process using the same store
+const (
+	defaultMaxRPS                = 10
+	defaultMaxConcurrentRequests = 8
+
+	limiterDir = "oneview-limits"
+	// limiterPoll - wait before checking again for a free in-flight slot
+	limiterPoll = 100 * time.Millisecond
+)
+
+// limiterState - token bucket of one appliance, kept in a file so all driver
+// processes on the host share it
+type limiterState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// limiter - token bucket rate limit and in-flight cap for one appliance.  Each
+// in-flight slot is a lock file held while the request runs, the system drops
+// the locks of a process that died so its slots are free right away.
+type limiter struct {
+	path        string
+	rps         int
+	maxInFlight int
+	now         func() time.Time
+	// sleep waits for a token or a slot, false when the wait was interrupted
+	sleep func(time.Duration) bool
+}
+
+// endpointChars - characters of an endpoint not kept in file names
+var endpointChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// endpointName - appliance endpoint usable in a file name
+func endpointName(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	return endpointChars.ReplaceAllString(endpoint, "_")
+}
+
+// limiterFile - state file name for an appliance endpoint
+func limiterFile(endpoint string) string {
+	return endpointName(endpoint) + ".json"
+}
+
+// slotPath - lock file path of in-flight slot n, next to the state file
+func (l *limiter) slotPath(n int) string {
+	return fmt.Sprintf("%s.slot%d", strings.TrimSuffix(l.path, ".json"), n)
+}
+
+// update - change the shared state while holding the lock
+func (l *limiter) update(fn func(s *limiterState)) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(l.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var s limiterState
+	if data, err := ioutil.ReadFile(l.path); err == nil {
+		if err := json.Unmarshal(data, &s); err != nil {
+			log.Debugf("resetting unreadable request limit state %s : %s", l.path, err)
+			s = limiterState{}
+		}
+	}
+	fn(&s)
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// take - take a token from the bucket.  Returns how long to wait before
+// trying again when none is available.
+func (l *limiter) take() (wait time.Duration, err error) {
+	err = l.update(func(s *limiterState) {
+		now := l.now()
+		burst := float64(l.rps)
+		if s.Updated.IsZero() || s.Updated.After(now) {
+			s.Tokens = burst
+		} else {
+			s.Tokens += now.Sub(s.Updated).Seconds() * float64(l.rps)
+		}
+		if s.Tokens > burst {
+			s.Tokens = burst
+		}
+		s.Updated = now
+
+		if s.Tokens < 1 {
+			wait = time.Duration((1 - s.Tokens) / float64(l.rps) * float64(time.Second))
+			return
+		}
+		s.Tokens--
+	})
+	return wait, err
+}
+
+// takeSlot - lock a free in-flight slot, nil when all slots are held
+func (l *limiter) takeSlot() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return nil, err
+	}
+	for n := 0; n < l.maxInFlight; n++ {
+		unlock, ok, err := tryLockFile(l.slotPath(n))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return unlock, nil
+		}
+	}
+	return nil, nil
+}
+
+// acquire - wait for a token and, with hold, an in-flight slot.  Call the
+// returned func once the request is done.
+func (l *limiter) acquire(hold bool) (func(), error) {
+	release := func() {}
+	if hold && l.maxInFlight > 0 {
+		for {
+			unlock, err := l.takeSlot()
+			if err != nil {
+				// never block appliance calls on a broken limit file
+				log.Warnf("Request limits are not applied, unable to use %s : %s", l.path, err)
+				return release, nil
+			}
+			if unlock != nil {
+				release = unlock
+				break
+			}
+			if l.sleep != nil && !l.sleep(limiterPoll) {
+				return nil, ErrDriverInterrupted
+			}
+		}
+	}
+	for l.rps > 0 {
+		wait, err := l.take()
+		if err != nil {
+			log.Warnf("Request limits are not applied, unable to use %s : %s", l.path, err)
+			break
+		}
+		if wait == 0 {
+			break
+		}
+		if l.sleep != nil && !l.sleep(wait) {
+			release()
+			return nil, ErrDriverInterrupted
+		}
+	}
+	return release, nil
+}
+
+// limiter - the request limits of the appliance at endpoint
+func (d *Driver) limiter(endpoint string) *limiter {
+	return &limiter{
+		path:        filepath.Join(d.StorePath, limiterDir, limiterFile(endpoint)),
+		rps:         d.MaxRPS,
+		maxInFlight: d.MaxConcurrentRequests,
+		now:         time.Now,
+		sleep:       d.sleep,
+	}
+}
+
+// libraryCall - a call of the OneView or ICSP library, which sends its own
+// requests and waits for its task.  Only its start is rate limited, holding
+// a slot for minutes would starve other machines, and it is recorded in the
+// audit file as one entry.
+func (d *Driver) libraryCall(appliance string, method string, path string, fn func() error) func() error {
+	return func() error {
+		d.applyAPIProxy()
+		done, err := d.limiter(d.applianceClient(appliance).Endpoint).acquire(false)
+		if err != nil {
+			return err
+		}
+		defer done()
+		start := time.Now()
+		err = libraryStatus(fn())
+		d.auditRequest(auditEntry{Appliance: appliance, Method: method, Path: path}, start, err)
+		return err
+	}
+}
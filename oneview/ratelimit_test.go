@@ -0,0 +1,210 @@
+package oneview
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLimiter - limiter on a state file in dir with a fake clock
+func testLimiter(dir string, rps int, maxInFlight int, now *time.Time) *limiter {
+	return &limiter{
+		path:        filepath.Join(dir, limiterFile("https://ov.example.com:443")),
+		rps:         rps,
+		maxInFlight: maxInFlight,
+		now:         func() time.Time { return *now },
+	}
+}
+
+// TestLimiterRate - the bucket allows a burst of rps requests then refills
+func TestLimiterRate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := testLimiter(dir, 2, 0, &now)
+	for i := 0; i < 2; i++ {
+		wait, err := l.take()
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+	wait, err := l.take()
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// a second process on the same store shares the bucket
+	other := testLimiter(dir, 2, 0, &now)
+	wait, _ = other.take()
+	assert.True(t, wait > 0)
+
+	now = now.Add(500 * time.Millisecond)
+	wait, _ = other.take()
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+// TestLimiterInFlight - slots are shared by the limiters of a store and
+// free again once released
+func TestLimiterInFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := testLimiter(dir, 0, 2, &now)
+	done1, err := l.acquire(true)
+	assert.NoError(t, err)
+	done2, err := testLimiter(dir, 0, 2, &now).acquire(true)
+	assert.NoError(t, err)
+	defer done2()
+
+	unlock, err := l.takeSlot()
+	assert.NoError(t, err)
+	assert.Nil(t, unlock)
+
+	slept := 0
+	l.sleep = func(time.Duration) bool {
+		slept++
+		if slept == 2 {
+			done1()
+		}
+		return true
+	}
+	done3, err := l.acquire(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, slept)
+	defer done3()
+
+	// a wait for a slot stops when interrupted
+	l.sleep = func(time.Duration) bool { return false }
+	_, err = l.acquire(true)
+	assert.Equal(t, ErrDriverInterrupted, err)
+}
+
+// TestLimiterSlotHolder - not a test, the process holding a slot for
+// TestLimiterDeadProcess
+func TestLimiterSlotHolder(t *testing.T) {
+	dir := os.Getenv("ONEVIEW_TEST_SLOT_DIR")
+	if dir == "" {
+		return
+	}
+	now := time.Now()
+	if _, err := testLimiter(dir, 0, 1, &now).acquire(true); err != nil {
+		os.Exit(1)
+	}
+	fmt.Println("holding")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// TestLimiterDeadProcess - the slot of a process that died is free again
+// right away
+func TestLimiterDeadProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLimiterSlotHolder$")
+	cmd.Env = append(os.Environ(), "ONEVIEW_TEST_SLOT_DIR="+dir)
+	out, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+	line, err := bufio.NewReader(out).ReadString('\n')
+	if !assert.NoError(t, err) {
+		cmd.Process.Kill()
+		return
+	}
+	assert.Equal(t, "holding\n", line)
+
+	now := time.Now()
+	l := testLimiter(dir, 0, 1, &now)
+	unlock, err := l.takeSlot()
+	assert.NoError(t, err)
+	assert.Nil(t, unlock)
+
+	assert.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+	unlock, err = l.takeSlot()
+	assert.NoError(t, err)
+	if assert.NotNil(t, unlock) {
+		unlock()
+	}
+}
+
+// TestLimiterWait - acquire waits for tokens and stops when interrupted
+func TestLimiterWait(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := testLimiter(dir, 1, 0, &now)
+	var waited time.Duration
+	l.sleep = func(d time.Duration) bool {
+		waited += d
+		now = now.Add(d)
+		return true
+	}
+	for i := 0; i < 3; i++ {
+		_, err := l.acquire(false)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2*time.Second, waited)
+
+	l.sleep = func(time.Duration) bool { return false }
+	_, err = l.acquire(false)
+	assert.Equal(t, ErrDriverInterrupted, err)
+}
+
+// TestLimiterDisabled - no limits means no state file
+func TestLimiterDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := testLimiter(dir, 0, 0, &now)
+	done, err := l.acquire(true)
+	assert.NoError(t, err)
+	done()
+	_, err = os.Stat(l.path)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "ov.example.com_443.json", limiterFile("https://ov.example.com:443"))
+}
+
+// TestLibraryCallLimit - a library call takes a token, no in-flight slot, so
+// the requests it waits on do not starve other machines
+func TestLibraryCallLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}, MaxRPS: 1, MaxConcurrentRequests: 1}
+	d.ClientOV = &ov.OVClient{}
+	d.ClientOV.Endpoint = "https://ov.example.com"
+	err = d.libraryCall(applianceOV, "POST", "/rest/server-profiles", func() error {
+		// a driver request made meanwhile still gets the only slot
+		l := d.limiter(d.ClientOV.Endpoint)
+		l.rps = 0
+		done, err := l.acquire(true)
+		if err == nil {
+			done()
+		}
+		return err
+	})()
+	assert.NoError(t, err)
+
+	// the token is spent
+	wait, err := d.limiter(d.ClientOV.Endpoint).take()
+	assert.NoError(t, err)
+	assert.True(t, wait > 0)
+}
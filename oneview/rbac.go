@@ -0,0 +1,153 @@
+package oneview
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// roles that grant the operations Create, Stop and Remove perform
+const (
+	roleInfrastructureAdmin = "Infrastructure administrator"
+	roleServerAdmin         = "Server administrator"
+	roleServerProfileAdmin  = "Server profile administrator"
+)
+
+// privilege - an operation the driver performs and the roles allowing it
+type privilege struct {
+	Operation string
+	Roles     []string
+}
+
+// ovPrivileges - operations performed on OneView
+var ovPrivileges = []privilege{
+	{Operation: "assign and delete server profiles", Roles: []string{roleInfrastructureAdmin, roleServerAdmin, roleServerProfileAdmin}},
+	{Operation: "power on and off server hardware", Roles: []string{roleInfrastructureAdmin, roleServerAdmin}},
+}
+
+// accountRoles - roles of an appliance user, older releases list role names,
+// scoped releases list permissions of a role within a scope
+type accountRoles struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []struct {
+		RoleName string `json:"roleName,omitempty"`
+		ScopeURI string `json:"scopeUri,omitempty"`
+	} `json:"permissions,omitempty"`
+}
+
+// grants - check if the account has one of roles, scopes lists the scopes a
+// matching role is limited to, empty when it applies to every resource
+func (a accountRoles) grants(roles []string) (granted bool, scopes []string) {
+	for _, want := range roles {
+		for _, have := range a.Roles {
+			if strings.EqualFold(want, have) {
+				return true, nil
+			}
+		}
+		for _, p := range a.Permissions {
+			if !strings.EqualFold(want, p.RoleName) {
+				continue
+			}
+			if p.ScopeURI == "" {
+				return true, nil
+			}
+			granted = true
+			scopes = append(scopes, p.ScopeURI)
+		}
+	}
+	return granted, scopes
+}
+
+// missingPrivileges - describe the operations the account can not perform
+func missingPrivileges(appliance string, user string, a accountRoles, privileges []privilege) (missing []string) {
+	for _, p := range privileges {
+		granted, scopes := a.grants(p.Roles)
+		if !granted {
+			missing = append(missing, fmt.Sprintf("%s user %s can not %s, it needs one of the roles: %s",
+				appliance, user, p.Operation, strings.Join(p.Roles, ", ")))
+			continue
+		}
+		if len(scopes) > 0 {
+			log.Warnf("%s user %s can %s only within scopes %s, the template, hardware and networks must be in those scopes",
+				appliance, user, p.Operation, strings.Join(scopes, ", "))
+		}
+	}
+	return missing
+}
+
+// accessProbe - a collection Create reads or writes on an appliance, read
+// with one member to learn if the account may use it
+type accessProbe struct {
+	Appliance string
+	URI       string
+	Operation string
+}
+
+// accessProbes - collections of the operations Create performs, the ICSP
+// roles are not documented so the ICSP account is only probed
+var accessProbes = []accessProbe{
+	{Appliance: applianceOV, URI: "/rest/server-profiles", Operation: "assign server profiles"},
+	{Appliance: applianceOV, URI: "/rest/server-hardware", Operation: "power on server hardware"},
+	{Appliance: applianceICSP, URI: "/rest/os-deployment-servers", Operation: "add servers"},
+	{Appliance: applianceICSP, URI: "/rest/os-deployment-build-plans", Operation: "run OS build plans"},
+	{Appliance: applianceICSP, URI: "/rest/os-deployment-jobs", Operation: "follow OS build plan jobs"},
+}
+
+// checkPrivileges - read the roles of the OneView account and compare them
+// with what Create will do, and probe the collections Create uses on both
+// appliances.  Returns the operations that are not allowed.  Directory
+// accounts have no local user to read and reading a local user takes the
+// read privilege on users and groups, an account whose roles can not be read
+// is reported unless --oneview-skip-role-check is set.
+func (d *Driver) checkPrivileges() (problems []string) {
+	problems = append(problems, d.checkOVRoles()...)
+	for _, p := range accessProbes {
+		problems = append(problems, d.probeAccess(p)...)
+	}
+	return problems
+}
+
+// checkOVRoles - compare the roles of the OneView account with ovPrivileges
+func (d *Driver) checkOVRoles() []string {
+	user := d.ClientOV.User
+	if !isLocalDomain(d.ClientOV.Domain) {
+		return d.unverifiedRoles(fmt.Sprintf("OneView user %s logs in from directory %s, its roles can not be read", user, d.ClientOV.Domain))
+	}
+	var roles accountRoles
+	err := d.ovCall(rest.GET, "/rest/users/"+url.QueryEscape(user), nil, &roles)
+	switch code := errorStatusCode(err); {
+	case err == nil:
+		return missingPrivileges("OneView", user, roles, ovPrivileges)
+	case code == http.StatusForbidden || code == http.StatusNotFound:
+		return d.unverifiedRoles(fmt.Sprintf("OneView user %s can not read its own account, its roles can not be read : %s", user, err))
+	}
+	return []string{fmt.Sprintf("Unable to read the roles of OneView user %s : %s", user, err)}
+}
+
+// unverifiedRoles - an account whose roles can not be read is a problem,
+// or a warning with --oneview-skip-role-check
+func (d *Driver) unverifiedRoles(reason string) []string {
+	if d.SkipRoleCheck {
+		log.Warnf("%s, the role check is skipped", reason)
+		return nil
+	}
+	return []string{reason + ", give it the read privilege on users and groups or set --oneview-skip-role-check"}
+}
+
+// probeAccess - read one member of a collection Create uses, an account
+// refused the read can not perform the operation either
+func (d *Driver) probeAccess(p accessProbe) []string {
+	user := d.applianceClient(p.Appliance).User
+	err := d.applianceCall(p.Appliance, rest.GET, p.URI+"?start=0&count=1", nil, nil, true)
+	switch code := errorStatusCode(err); {
+	case err == nil:
+		return nil
+	case code == http.StatusForbidden:
+		return []string{fmt.Sprintf("%s user %s can not %s, it is refused %s", applianceName(p.Appliance), user, p.Operation, p.URI)}
+	}
+	return []string{fmt.Sprintf("Unable to read %s on %s as user %s : %s", p.URI, applianceName(p.Appliance), user, err)}
+}
@@ -0,0 +1,99 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMissingPrivileges - roles and scoped permissions are compared with the operations
+func TestMissingPrivileges(t *testing.T) {
+	var admin, readOnly, scoped accountRoles
+	assert.NoError(t, json.Unmarshal([]byte(`{"roles": ["infrastructure administrator"]}`), &admin))
+	assert.NoError(t, json.Unmarshal([]byte(`{"roles": ["Read only"]}`), &readOnly))
+	assert.NoError(t, json.Unmarshal([]byte(`{"permissions": [
+		{"roleName": "Server profile administrator"},
+		{"roleName": "Server administrator", "scopeUri": "/rest/scopes/lab"}]}`), &scoped))
+
+	assert.Empty(t, missingPrivileges("OneView", "admin", admin, ovPrivileges))
+	assert.Len(t, missingPrivileges("OneView", "reader", readOnly, ovPrivileges), len(ovPrivileges))
+	assert.Empty(t, missingPrivileges("OneView", "scoped", scoped, ovPrivileges))
+
+	granted, scopes := scoped.grants([]string{roleServerAdmin})
+	assert.True(t, granted)
+	assert.Equal(t, []string{"/rest/scopes/lab"}, scopes)
+}
+
+// TestCheckPrivileges - roles are compared for local accounts, accounts
+// whose roles can not be read are reported unless the check is skipped
+func TestCheckPrivileges(t *testing.T) {
+	var users []string
+	answer := `{"roles": ["Infrastructure administrator"]}`
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/rest/users/") {
+			fmt.Fprint(w, `{"members": []}`)
+			return
+		}
+		users = append(users, r.URL.Path)
+		if answer == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errorCode": "AUTHORIZATION", "message": "Authorization error"}`)
+			return
+		}
+		fmt.Fprint(w, answer)
+	})
+	defer done()
+	assert.Empty(t, d.checkPrivileges())
+	assert.Equal(t, []string{"/rest/users/admin"}, users)
+
+	answer = `{"roles": ["Read only"]}`
+	assert.Len(t, d.checkPrivileges(), len(ovPrivileges))
+
+	// a server administrator without the read privilege on users
+	answer = ""
+	problems := d.checkPrivileges()
+	if assert.Len(t, problems, 1) {
+		assert.Contains(t, problems[0], "can not read its own account")
+		assert.Contains(t, problems[0], "--oneview-skip-role-check")
+	}
+	d.SkipRoleCheck = true
+	assert.Empty(t, d.checkPrivileges())
+
+	users = nil
+	d.SkipRoleCheck = false
+	d.ClientOV.User, d.ClientOV.Domain = splitUserPrincipal("docker@corp.example.com", "")
+	problems = d.checkPrivileges()
+	if assert.Len(t, problems, 1) {
+		assert.Contains(t, problems[0], "logs in from directory corp.example.com")
+	}
+	assert.Empty(t, users)
+}
+
+// TestProbeAccess - every collection create uses is read once on its
+// appliance, a refused read is a missing privilege
+func TestProbeAccess(t *testing.T) {
+	var probed []string
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/rest/users/") {
+			fmt.Fprint(w, `{"roles": ["Infrastructure administrator"]}`)
+			return
+		}
+		assert.Equal(t, "start=0&count=1", r.URL.RawQuery)
+		probed = append(probed, r.URL.Path)
+		if r.URL.Path == "/rest/os-deployment-jobs" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errorCode": "AUTHORIZATION", "message": "Authorization error"}`)
+			return
+		}
+		fmt.Fprint(w, `{"members": []}`)
+	})
+	defer done()
+
+	problems := d.checkPrivileges()
+	assert.Equal(t, []string{"ICSP user admin can not follow OS build plan jobs, it is refused /rest/os-deployment-jobs"}, problems)
+	assert.Len(t, probed, len(accessProbes))
+}
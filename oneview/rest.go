@@ -0,0 +1,204 @@
+package oneview
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+)
+
+// appliance resources used without a session
+const (
+	loginSessionsURI = "/rest/login-sessions"
+	versionURI       = "/rest/version"
+)
+
+// apiSessions - login sessions of the appliances, shared by the requests of
+// the process
+type apiSessions struct {
+	sync.Mutex
+	ids map[string]string
+}
+
+// loginCredentials - body of a login session request
+type loginCredentials struct {
+	UserName string `json:"userName"`
+	Password string `json:"password"`
+	Domain   string `json:"authLoginDomain,omitempty"`
+}
+
+// applianceClient - endpoint, credentials and api version of an appliance
+func (d *Driver) applianceClient(appliance string) *rest.Client {
+	if appliance == applianceICSP {
+		return &d.ClientICSP.Client
+	}
+	return &d.ClientOV.Client
+}
+
+// applianceName - appliance name for messages
+func applianceName(appliance string) string {
+	if appliance == applianceICSP {
+		return "ICSP"
+	}
+	return "OneView"
+}
+
+// apiRequest - send one request to an appliance, with the login session when
+// session is set.  The answer is returned for 2xx statuses, other statuses
+// are a statusError.
+func (d *Driver) apiRequest(appliance string, method string, uri string, payload []byte, session string) ([]byte, error) {
+	c := d.applianceClient(appliance)
+	req, err := http.NewRequest(method, c.Endpoint+uri, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the version resource is read before a version is picked
+	if v := d.requestVersion(appliance, uri); v > 0 && uri != versionURI {
+		req.Header.Set("X-API-Version", strconv.Itoa(v))
+	}
+	if session != "" {
+		req.Header.Set("Auth", session)
+	}
+	// an interrupt cuts the request short
+	cancel := d.interrupted()
+	req.Cancel = cancel
+	resp, err := d.apiClient(appliance, c.Endpoint, c.SSLVerify).Do(req)
+	if err != nil {
+		select {
+		case <-cancel:
+			return nil, ErrDriverInterrupted
+		default:
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, statusError{Method: method, Path: uri, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(data)}
+	}
+	return data, nil
+}
+
+// session - the login session of the appliance, logging in on first use
+func (d *Driver) session(appliance string) (string, error) {
+	d.sessions.Lock()
+	defer d.sessions.Unlock()
+	if id := d.sessions.ids[appliance]; id != "" {
+		return id, nil
+	}
+	c := d.applianceClient(appliance)
+	payload, err := json.Marshal(loginCredentials{UserName: c.User, Password: c.Password, Domain: c.Domain})
+	if err != nil {
+		return "", err
+	}
+	data, err := d.apiRequest(appliance, "POST", loginSessionsURI, payload, "")
+	if err != nil {
+		return "", fmt.Errorf("Unable to login to %s %s : %s", applianceName(appliance), c.Endpoint, err)
+	}
+	var s struct {
+		SessionID string `json:"sessionID"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil || s.SessionID == "" {
+		return "", fmt.Errorf("Unable to login to %s %s, no session in the answer", applianceName(appliance), c.Endpoint)
+	}
+	if d.sessions.ids == nil {
+		d.sessions.ids = make(map[string]string)
+	}
+	d.sessions.ids[appliance] = s.SessionID
+	return s.SessionID, nil
+}
+
+// forgetSession - drop an expired session, the next request logs in again
+func (d *Driver) forgetSession(appliance string, id string) {
+	d.sessions.Lock()
+	defer d.sessions.Unlock()
+	if d.sessions.ids[appliance] == id {
+		delete(d.sessions.ids, appliance)
+	}
+}
+
+// logout - end the login session of the appliance, when there is one
+func (d *Driver) logout(appliance string) error {
+	d.sessions.Lock()
+	id := d.sessions.ids[appliance]
+	delete(d.sessions.ids, appliance)
+	d.sessions.Unlock()
+	if id == "" {
+		return nil
+	}
+	_, err := d.apiRequest(appliance, "DELETE", loginSessionsURI, nil, id)
+	return err
+}
+
+// applianceCall - a request with the login session of the appliance, logging
+// in again once when the session expired, retried as idempotent says.  The
+// answer is decoded into out when out is not nil.
+func (d *Driver) applianceCall(appliance string, method rest.Method, uri string, options interface{}, out interface{}, idempotent bool) error {
+	var payload []byte
+	if options != nil {
+		var err error
+		if payload, err = json.Marshal(options); err != nil {
+			return err
+		}
+	}
+	var data []byte
+	err := d.retry(method.String()+" "+uri, idempotent, func() error {
+		for attempt := 0; ; attempt++ {
+			session, err := d.session(appliance)
+			if err != nil {
+				return err
+			}
+			data, err = d.apiRequest(appliance, method.String(), uri, payload, session)
+			if errorStatusCode(err) != http.StatusUnauthorized || attempt > 0 {
+				return err
+			}
+			d.forgetSession(appliance, session)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// applianceGet - a request to a resource that needs no session, like the
+// api version or the login domains
+func (d *Driver) applianceGet(appliance string, uri string, out interface{}) error {
+	return d.retry("GET "+uri, true, func() error {
+		data, err := d.apiRequest(appliance, "GET", uri, nil, "")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, out)
+	})
+}
+
+// ovCall - call a OneView REST resource, the response is decoded into out
+// when out is not nil
+func (d *Driver) ovCall(method rest.Method, uri string, options interface{}, out interface{}) error {
+	return d.applianceCall(applianceOV, method, uri, options, out, isIdempotent(method))
+}
+
+// ovCallOnce - ovCall for requests that change state each time they run,
+// like id allocations, only retried when they never reached the appliance
+func (d *Driver) ovCallOnce(method rest.Method, uri string, options interface{}, out interface{}) error {
+	return d.applianceCall(applianceOV, method, uri, options, out, false)
+}
+
+// icspCall - call an ICSP REST resource, the response is decoded into out
+// when out is not nil
+func (d *Driver) icspCall(method rest.Method, uri string, options interface{}, out interface{}) error {
+	return d.applianceCall(applianceICSP, method, uri, options, out, isIdempotent(method))
+}
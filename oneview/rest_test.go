@@ -0,0 +1,83 @@
+package oneview
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// applianceStub - OneView and ICSP stub answering logins itself, other
+// requests go to handler
+type applianceStub struct {
+	sync.Mutex
+	logins  int
+	handler http.HandlerFunc
+}
+
+func (s *applianceStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && r.URL.Path == loginSessionsURI {
+		s.Lock()
+		s.logins++
+		n := s.logins
+		s.Unlock()
+		fmt.Fprintf(w, `{"sessionID": "session-%d"}`, n)
+		return
+	}
+	if r.Method == "DELETE" && r.URL.Path == loginSessionsURI {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.handler(w, r)
+}
+
+// testApplianceDriver - a driver named test in a temporary store, talking to
+// OneView and ICSP stubs served by handler.  Call the returned func when done.
+func testApplianceDriver(t *testing.T, handler http.HandlerFunc) (*Driver, *applianceStub, func()) {
+	dir, err := ioutil.TempDir("", "oneview-rest")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "test"), 0700))
+	stub := &applianceStub{handler: handler}
+	srv := httptest.NewServer(stub)
+	client := rest.Client{User: "admin", Password: "secret", Endpoint: srv.URL, APIVersion: 200}
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir},
+		ClientOV:   &ov.OVClient{Client: client},
+		ClientICSP: &icsp.ICSPClient{Client: client},
+	}
+	return d, stub, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// TestApplianceCallRelogin - an expired session is replaced once
+func TestApplianceCallRelogin(t *testing.T) {
+	var auth []string
+	d, stub, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Auth"))
+		assert.Equal(t, "200", r.Header.Get("X-API-Version"))
+		if r.Header.Get("Auth") == "session-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"name": "blade"}`)
+	})
+	defer done()
+
+	var hw ov.ServerHardware
+	assert.NoError(t, d.ovCall(rest.GET, "/rest/server-hardware/1", nil, &hw))
+	assert.Equal(t, "blade", hw.Name)
+	assert.Equal(t, []string{"session-1", "session-2"}, auth)
+	assert.Equal(t, 2, stub.logins)
+}
@@ -0,0 +1,198 @@
+package oneview
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// retry defaults
+const (
+	defaultRetryAttempts = 5
+	defaultRetryDelay    = 2 * time.Second
+	defaultRetryMaxDelay = 30 * time.Second
+)
+
+// statusError - an appliance answered with an http error status
+type statusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// Error - same shape as the errors of the appliance clients
+func (e statusError) Error() string {
+	return fmt.Sprintf("Error in response: %s %s, %s\n Response Status: %s", e.Method, e.Path, e.Body, e.Status)
+}
+
+// libraryError - an error of the OneView or ICSP library with the http
+// status it reports in its text, the text is kept as the library wrote it
+type libraryError struct {
+	err        error
+	StatusCode int
+}
+
+// Error - the library error text
+func (e libraryError) Error() string {
+	return e.err.Error()
+}
+
+// responseStatus - status code in the error text of the library clients
+var responseStatus = regexp.MustCompile(`Response Status: (\d{3})`)
+
+// libraryStatus - type an error returned by the library, so its status is
+// read once where the library returns and not guessed from any error text
+func libraryStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	m := responseStatus.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	code, _ := strconv.Atoi(m[1])
+	return libraryError{err: err, StatusCode: code}
+}
+
+// transient error text, from the network or from appliance tasks asking for a retry
+var (
+	transientNetwork = []string{"connection reset", "connection refused", "broken pipe", "i/o timeout", "tls handshake timeout", "unexpected eof", ": eof"}
+	transientTask    = []string{"please retry", "try again", "resource busy", "is busy"}
+)
+
+// errorStatusCode - http status of an appliance error, 0 when there is none
+// or the error did not come from an appliance answer
+func errorStatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch e := err.(type) {
+	case statusError:
+		return e.StatusCode
+	case libraryError:
+		return e.StatusCode
+	}
+	return 0
+}
+
+// isTransient - check if err is worth retrying, non idempotent calls are only
+// retried when the appliance surely did not act on the request
+func isTransient(err error, idempotent bool) bool {
+	code := errorStatusCode(err)
+	msg := strings.ToLower(err.Error())
+	if !idempotent {
+		return code == 429 || strings.Contains(msg, "connection refused")
+	}
+	if code == 409 || code == 429 || code >= 500 {
+		return true
+	}
+	for _, s := range transientNetwork {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	for _, s := range transientTask {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicy - bounded retries with jittered exponential backoff
+type retryPolicy struct {
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// sleep waits between attempts, false when the wait was interrupted
+	sleep func(time.Duration) bool
+}
+
+// jitter - random source shared by all policies
+var (
+	jitterLock sync.Mutex
+	jitter     = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff - wait before retry n, a random time up to the exponential delay
+func (p retryPolicy) backoff(n uint) time.Duration {
+	delay := p.Delay << n
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	jitterLock.Lock()
+	defer jitterLock.Unlock()
+	return delay/2 + time.Duration(jitter.Int63n(int64(delay/2)+1))
+}
+
+// do - run fn until it succeeds, fails with a permanent error or runs out of attempts
+func (p retryPolicy) do(op string, idempotent bool, fn func() error) (err error) {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for n := 0; n < attempts; n++ {
+		if err = fn(); err == nil || !isTransient(err, idempotent) {
+			return err
+		}
+		if n == attempts-1 {
+			break
+		}
+		delay := p.backoff(uint(n))
+		log.Debugf("%s failed, retry %d of %d in %s : %s", op, n+1, attempts-1, delay, err)
+		if p.sleep != nil && !p.sleep(delay) {
+			return ErrDriverInterrupted
+		}
+	}
+	return err
+}
+
+// retryPolicy - the policy for appliance calls made by this driver, zero
+// attempts means the call is made once and never retried
+func (d *Driver) retryPolicy() retryPolicy {
+	p := retryPolicy{
+		Attempts: d.RetryAttempts,
+		Delay:    d.RetryDelay,
+		MaxDelay: d.RetryMaxDelay,
+		sleep:    d.sleep,
+	}
+	if p.Delay <= 0 {
+		p.Delay = defaultRetryDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	return p
+}
+
+// sleep - wait for delay, false when the driver was interrupted first
+func (d *Driver) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-d.interrupted():
+		return false
+	}
+}
+
+// retry - run an appliance call with the driver retry policy
+func (d *Driver) retry(op string, idempotent bool, fn func() error) error {
+	return d.retryPolicy().do(op, idempotent, fn)
+}
+
+// isIdempotent - methods that can be repeated without changing the outcome
+func isIdempotent(method rest.Method) bool {
+	return method != rest.POST
+}
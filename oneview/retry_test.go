@@ -0,0 +1,145 @@
+package oneview
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// faultServer - iLO stub that fails the first requests, alternating between
+// a 503 answer and a dropped connection
+type faultServer struct {
+	sync.Mutex
+	failures int
+	requests int
+}
+
+func (f *faultServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.requests++
+	n := f.requests
+	f.Unlock()
+	if n <= f.failures {
+		if n%2 == 0 {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method == "POST" {
+		w.Header().Set("Location", iloAccountsURI+"3/")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// testPolicy - retry policy that does not wait
+func testPolicy(attempts int) retryPolicy {
+	return retryPolicy{Attempts: attempts, Delay: time.Millisecond, MaxDelay: time.Millisecond,
+		sleep: func(time.Duration) bool { return true }}
+}
+
+// TestRetryIdempotent - a delete survives 503 answers and dropped connections
+func TestRetryIdempotent(t *testing.T) {
+	fault := &faultServer{failures: 3}
+	server := httptest.NewTLSServer(fault)
+	defer server.Close()
+
+	c := &iloClient{Endpoint: server.URL, Token: "session", policy: testPolicy(5)}
+	assert.NoError(t, c.deleteAccount(iloAccountsURI+"3/"))
+	assert.Equal(t, 4, fault.requests)
+
+	fault.requests = 0
+	c.policy = testPolicy(3)
+	assert.Error(t, c.deleteAccount(iloAccountsURI+"3/"))
+	assert.Equal(t, 3, fault.requests)
+}
+
+// TestRetryNotIdempotent - a create is not repeated when the appliance may have acted on it
+func TestRetryNotIdempotent(t *testing.T) {
+	fault := &faultServer{failures: 1}
+	server := httptest.NewTLSServer(fault)
+	defer server.Close()
+
+	c := &iloClient{Endpoint: server.URL, Token: "session", policy: testPolicy(5)}
+	_, err := c.createAccount("dm-test", "secret")
+	assert.Error(t, err)
+	assert.Equal(t, 503, errorStatusCode(err))
+	assert.Equal(t, 1, fault.requests)
+}
+
+// TestIsTransient - errors worth retrying
+func TestIsTransient(t *testing.T) {
+	busy := libraryStatus(errors.New("Error in response: PUT /rest/server-hardware/1/powerState, {}\n Response Status: 409 Conflict"))
+	assert.Equal(t, 409, errorStatusCode(busy))
+	assert.Contains(t, busy.Error(), "Response Status: 409 Conflict")
+	assert.Equal(t, 0, errorStatusCode(errors.New("Unable to find the profile, Response Status: 404")))
+	assert.True(t, isTransient(busy, true))
+	assert.False(t, isTransient(busy, false))
+
+	assert.True(t, isTransient(statusError{StatusCode: 429}, false))
+	assert.True(t, isTransient(statusError{StatusCode: 502}, true))
+	assert.False(t, isTransient(statusError{StatusCode: 502}, false))
+	assert.False(t, isTransient(statusError{StatusCode: 404}, true))
+	assert.True(t, isTransient(errors.New("dial tcp 10.0.0.1:443: connection refused"), false))
+	assert.True(t, isTransient(errors.New("read tcp: connection reset by peer"), true))
+	assert.True(t, isTransient(errors.New("The appliance is busy, please retry later"), true))
+	assert.False(t, isTransient(errors.New("Unable to find the profile"), true))
+}
+
+// TestRetryBackoff - waits grow exponentially with jitter and stay under the max
+func TestRetryBackoff(t *testing.T) {
+	p := retryPolicy{Delay: time.Second, MaxDelay: 10 * time.Second}
+	for i := 0; i < 20; i++ {
+		d := p.backoff(0)
+		assert.True(t, d >= 500*time.Millisecond && d <= time.Second, "backoff(0) = %s", d)
+		d = p.backoff(2)
+		assert.True(t, d >= 2*time.Second && d <= 4*time.Second, "backoff(2) = %s", d)
+		d = p.backoff(40)
+		assert.True(t, d >= 5*time.Second && d <= 10*time.Second, "backoff(40) = %s", d)
+	}
+}
+
+// TestRetryInterrupted - an interrupted wait stops the retries
+func TestRetryInterrupted(t *testing.T) {
+	p := retryPolicy{Attempts: 5, sleep: func(time.Duration) bool { return false }}
+	calls := 0
+	err := p.do("test", true, func() error {
+		calls++
+		return statusError{StatusCode: 503}
+	})
+	assert.Equal(t, ErrDriverInterrupted, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestRetryNoRetries - zero attempts makes the call once, the default only
+// comes from the flag
+func TestRetryNoRetries(t *testing.T) {
+	d := &Driver{RetryAttempts: 0, RetryDelay: time.Millisecond}
+	calls := 0
+	err := d.retry("test", true, func() error {
+		calls++
+		return statusError{StatusCode: 503}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	d.RetryAttempts = 3
+	calls = 0
+	assert.Error(t, d.retry("test", true, func() error {
+		calls++
+		return statusError{StatusCode: 503}
+	}))
+	assert.Equal(t, 3, calls)
+}
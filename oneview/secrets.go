@@ -0,0 +1,70 @@
+package oneview
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// secretsFile - per machine file for credentials that are kept out of config.json
+const secretsFile = "secrets.json"
+
+// readSecrets - load the machine secrets, an empty set when none were saved
+func (d *Driver) readSecrets() (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := ioutil.ReadFile(d.ResolveStorePath(secretsFile))
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// writeSecrets - save the machine secrets readable only by the current user
+func (d *Driver) writeSecrets(secrets map[string]string) error {
+	if len(secrets) == 0 {
+		if err := os.Remove(d.ResolveStorePath(secretsFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.ResolveStorePath(secretsFile), data, 0600)
+}
+
+// saveSecret - keep a secret for this machine
+func (d *Driver) saveSecret(name string, value string) error {
+	secrets, err := d.readSecrets()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return d.writeSecrets(secrets)
+}
+
+// getSecret - get a secret saved for this machine
+func (d *Driver) getSecret(name string) (string, error) {
+	secrets, err := d.readSecrets()
+	if err != nil {
+		return "", err
+	}
+	return secrets[name], nil
+}
+
+// deleteSecret - forget a secret saved for this machine
+func (d *Driver) deleteSecret(name string) error {
+	secrets, err := d.readSecrets()
+	if err != nil {
+		return err
+	}
+	delete(secrets, name)
+	return d.writeSecrets(secrets)
+}
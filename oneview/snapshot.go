@@ -0,0 +1,288 @@
+package oneview
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/icsp"
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	snapshotDir = "oneview-snapshots"
+	// snapshotTTL - how long a status snapshot is shared by the machines of
+	// an appliance, long enough for one docker-machine ls
+	snapshotTTL = 10 * time.Second
+	// snapshotMinMachines - machines asking for their state within
+	// snapshotTTL before the snapshot is listed, a single status is looked up
+	snapshotMinMachines = 3
+	// listMaxPages - guard against appliances paging forever
+	listMaxPages = 100
+)
+
+// snapshotProfile - server profile fields needed for the status
+type snapshotProfile struct {
+	Name              string `json:"name"`
+	ServerHardwareURI string `json:"serverHardwareUri"`
+}
+
+// snapshotHardware - server hardware fields needed for the status
+type snapshotHardware struct {
+	URI                 string `json:"uri"`
+	PowerState          string `json:"powerState"`
+	SerialNumber        string `json:"serialNumber"`
+	VirtualSerialNumber string `json:"virtualSerialNumber"`
+}
+
+// snapshotServer - ICSP server fields needed for the status
+type snapshotServer struct {
+	MID           string `json:"mid"`
+	SerialNumber  string `json:"serialNumber"`
+	OpswLifecycle string `json:"opswLifecycle"`
+}
+
+// statusSnapshot - profiles, power states and ICSP lifecycles of one
+// appliance, listed once and read by the GetState of every machine
+type statusSnapshot struct {
+	Fetched  time.Time                   `json:"fetched"`
+	Profiles map[string]snapshotProfile  `json:"profiles"`
+	Hardware map[string]snapshotHardware `json:"hardware"`
+	Servers  map[string]snapshotServer   `json:"servers"`
+}
+
+// resourcePage - one page of an appliance resource list
+type resourcePage struct {
+	Members     []json.RawMessage `json:"members"`
+	NextPageURI string            `json:"nextPageUri"`
+}
+
+// listAll - every member of a paged resource list, decoded into out.  A
+// list longer than listMaxPages is an error rather than a partial list.
+func listAll(call func(rest.Method, string, interface{}, interface{}) error, uri string, out interface{}) error {
+	var members []json.RawMessage
+	for page := 0; uri != ""; page++ {
+		if page == listMaxPages {
+			return fmt.Errorf("Listing %s stopped after %d pages, the appliance keeps returning more", uri, listMaxPages)
+		}
+		var p resourcePage
+		if err := call(rest.GET, uri, nil, &p); err != nil {
+			return err
+		}
+		members = append(members, p.Members...)
+		if p.NextPageURI == uri {
+			break
+		}
+		uri = p.NextPageURI
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// fetchSnapshot - list the profiles, hardware and ICSP servers of the appliances
+func (d *Driver) fetchSnapshot() (*statusSnapshot, error) {
+	var (
+		profiles []snapshotProfile
+		hardware []snapshotHardware
+		servers  []snapshotServer
+	)
+	if err := listAll(d.ovCall, "/rest/server-profiles", &profiles); err != nil {
+		return nil, err
+	}
+	if err := listAll(d.ovCall, "/rest/server-hardware", &hardware); err != nil {
+		return nil, err
+	}
+	if err := listAll(d.icspCall, "/rest/os-deployment-servers", &servers); err != nil {
+		return nil, err
+	}
+
+	s := &statusSnapshot{
+		Fetched:  time.Now(),
+		Profiles: make(map[string]snapshotProfile, len(profiles)),
+		Hardware: make(map[string]snapshotHardware, len(hardware)),
+		Servers:  make(map[string]snapshotServer, len(servers)),
+	}
+	for _, p := range profiles {
+		s.Profiles[p.Name] = p
+	}
+	for _, h := range hardware {
+		s.Hardware[h.URI] = h
+	}
+	for _, v := range servers {
+		s.Servers[strings.ToUpper(v.SerialNumber)] = v
+	}
+	log.Debugf("status snapshot with %d profiles, %d blades and %d icsp servers", len(profiles), len(hardware), len(servers))
+	return s, nil
+}
+
+// snapshotPath - snapshot file shared by the machines of the same appliances
+func (d *Driver) snapshotPath() string {
+	return filepath.Join(d.StorePath, snapshotDir,
+		endpointName(d.ClientOV.Endpoint)+"-"+endpointName(d.ClientICSP.Endpoint)+".json")
+}
+
+// readSnapshot - the snapshot at path, nil when missing or older than snapshotTTL
+func readSnapshot(path string) *statusSnapshot {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var s statusSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	if age := time.Since(s.Fetched); age < 0 || age > snapshotTTL {
+		return nil
+	}
+	return &s
+}
+
+// errSnapshotCold - too few machines asked for their state to list the appliances
+var errSnapshotCold = errors.New("status snapshot expired and too few machines asking to refresh it")
+
+// snapshotDemand - record that machine asked for the snapshot, returns the
+// number of machines that asked within snapshotTTL.  Called with the snapshot
+// lock held.
+func snapshotDemand(path string, machine string) int {
+	asked := map[string]time.Time{}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(data, &asked)
+	}
+	now := time.Now()
+	for name, at := range asked {
+		if age := now.Sub(at); age < 0 || age > snapshotTTL {
+			delete(asked, name)
+		}
+	}
+	asked[machine] = now
+	if data, err := json.Marshal(asked); err == nil {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			log.Debugf("unable to record status snapshot demand : %s", err)
+		}
+	}
+	return len(asked)
+}
+
+// getSnapshot - the shared status snapshot.  An expired snapshot is only
+// listed again once snapshotMinMachines machines asked for it, as during
+// docker-machine ls, by the machine that finds it expired while the others
+// wait for it.  Until then errSnapshotCold sends machines to a direct lookup.
+func (d *Driver) getSnapshot() (*statusSnapshot, error) {
+	path := d.snapshotPath()
+	if s := readSnapshot(path); s != nil {
+		return s, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if s := readSnapshot(path); s != nil {
+		return s, nil
+	}
+	if snapshotDemand(path+".demand", d.MachineName) < snapshotMinMachines {
+		return nil, errSnapshotCold
+	}
+
+	s, err := d.fetchSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return nil, err
+	}
+	return s, os.Rename(path+".tmp", path)
+}
+
+// machineState - state of the machine from the snapshot, false when the
+// machine is not in it yet
+func (s *statusSnapshot) machineState(name string) (state.State, bool) {
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return state.None, false
+	}
+	hw, ok := s.Hardware[profile.ServerHardwareURI]
+	if !ok {
+		return state.None, false
+	}
+	serial := hw.SerialNumber
+	if hw.VirtualSerialNumber != "" {
+		serial = hw.VirtualSerialNumber
+	}
+	server, ok := s.Servers[strings.ToUpper(serial)]
+	if !ok {
+		return state.None, false
+	}
+	if st, ok := lifecycleState(server.OpswLifecycle); ok {
+		return st, true
+	}
+	switch hw.PowerState {
+	case "On":
+		return state.Running, true
+	case "Off":
+		return state.Stopped, true
+	case "Unknown":
+		return state.Error, true
+	default:
+		return state.None, true
+	}
+}
+
+// lifecycleState - machine state given by the ICSP server lifecycle, false
+// when the power state decides
+func lifecycleState(lifecycle string) (state.State, bool) {
+	switch {
+	case icsp.Provisioning.Equal(lifecycle):
+		return state.Starting, true
+	case icsp.Unprovisioned.Equal(lifecycle), icsp.PreUnProvisioned.Equal(lifecycle):
+		return state.Stopping, true
+	case icsp.Deactivated.Equal(lifecycle):
+		return state.Stopped, true
+	case icsp.ProvisionedFailed.Equal(lifecycle):
+		return state.Error, true
+	}
+	return state.None, false
+}
+
+// invalidateCaches - drop the machine cache and the shared snapshot after the
+// machine changed state, so the next status is looked up
+func (d *Driver) invalidateCaches() {
+	d.invalidateBladeCache()
+	if d.BaseDriver == nil || d.StorePath == "" || d.ClientOV == nil || d.ClientICSP == nil {
+		return
+	}
+	if err := os.Remove(d.snapshotPath()); err != nil && !os.IsNotExist(err) {
+		log.Debugf("unable to remove status snapshot : %s", err)
+	}
+}
+
+// snapshotState - state of the machine from the shared snapshot, false when
+// it has to be looked up
+func (d *Driver) snapshotState() (state.State, bool) {
+	if !d.useCache() {
+		return state.None, false
+	}
+	s, err := d.getSnapshot()
+	if err != nil {
+		log.Debugf("status snapshot not available, looking up %s : %s", d.MachineName, err)
+		return state.None, false
+	}
+	return s.machineState(d.MachineName)
+}
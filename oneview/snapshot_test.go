@@ -0,0 +1,127 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/rest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListAll - members of every page are collected
+func TestListAll(t *testing.T) {
+	pages := map[string]string{
+		"/rest/server-hardware":         `{"members": [{"uri": "/rest/server-hardware/1", "powerState": "On"}], "nextPageUri": "/rest/server-hardware?start=1"}`,
+		"/rest/server-hardware?start=1": `{"members": [{"uri": "/rest/server-hardware/2", "powerState": "Off"}], "nextPageUri": "/rest/server-hardware?start=2"}`,
+		"/rest/server-hardware?start=2": `{"members": [], "nextPageUri": "/rest/server-hardware?start=2"}`,
+	}
+	calls := 0
+	call := func(method rest.Method, uri string, options interface{}, out interface{}) error {
+		calls++
+		return json.Unmarshal([]byte(pages[uri]), out)
+	}
+
+	var hardware []snapshotHardware
+	assert.NoError(t, listAll(call, "/rest/server-hardware", &hardware))
+	assert.Equal(t, 3, calls)
+	assert.Len(t, hardware, 2)
+	assert.Equal(t, "Off", hardware[1].PowerState)
+
+	// an appliance paging forever is an error, not a partial list
+	calls = 0
+	endless := func(method rest.Method, uri string, options interface{}, out interface{}) error {
+		calls++
+		return json.Unmarshal([]byte(fmt.Sprintf(`{"members": [{}], "nextPageUri": "/rest/server-hardware?start=%d"}`, calls)), out)
+	}
+	assert.Error(t, listAll(endless, "/rest/server-hardware", &hardware))
+	assert.Equal(t, listMaxPages, calls)
+}
+
+// TestGetSnapshotDemand - an expired snapshot is only listed once several
+// machines ask for their state, as ls does, a single status is looked up
+func TestGetSnapshotDemand(t *testing.T) {
+	var lists []string
+	d, _, done := testApplianceDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		lists = append(lists, r.URL.Path)
+		fmt.Fprint(w, `{"members": []}`)
+	})
+	defer done()
+
+	for i := 1; i < snapshotMinMachines; i++ {
+		d.MachineName = fmt.Sprintf("m%d", i)
+		_, err := d.getSnapshot()
+		assert.Equal(t, errSnapshotCold, err)
+		// asking again does not count twice
+		_, err = d.getSnapshot()
+		assert.Equal(t, errSnapshotCold, err)
+	}
+	assert.Empty(t, lists)
+
+	d.MachineName = "last"
+	s, err := d.getSnapshot()
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+	assert.Equal(t, []string{"/rest/server-profiles", "/rest/server-hardware", "/rest/os-deployment-servers"}, lists)
+
+	d.MachineName = "m1"
+	_, err = d.getSnapshot()
+	assert.NoError(t, err)
+	assert.Len(t, lists, 3)
+}
+
+// TestSnapshotMachineState - state from the snapshot, lifecycle before power
+func TestSnapshotMachineState(t *testing.T) {
+	s := &statusSnapshot{
+		Profiles: map[string]snapshotProfile{
+			"running":     {Name: "running", ServerHardwareURI: "/hw/1"},
+			"deploying":   {Name: "deploying", ServerHardwareURI: "/hw/2"},
+			"not-in-icsp": {Name: "not-in-icsp", ServerHardwareURI: "/hw/3"},
+		},
+		Hardware: map[string]snapshotHardware{
+			"/hw/1": {URI: "/hw/1", PowerState: "On", SerialNumber: "SN1", VirtualSerialNumber: "vsn1"},
+			"/hw/2": {URI: "/hw/2", PowerState: "On", SerialNumber: "SN2"},
+			"/hw/3": {URI: "/hw/3", PowerState: "Off", SerialNumber: "SN3"},
+		},
+		Servers: map[string]snapshotServer{
+			"VSN1": {MID: "1", SerialNumber: "VSN1", OpswLifecycle: "MANAGED"},
+			"SN2":  {MID: "2", SerialNumber: "SN2", OpswLifecycle: "PROVISIONING"},
+		},
+	}
+
+	st, ok := s.machineState("running")
+	assert.True(t, ok)
+	assert.Equal(t, state.Running, st)
+
+	st, ok = s.machineState("deploying")
+	assert.True(t, ok)
+	assert.Equal(t, state.Starting, st)
+
+	_, ok = s.machineState("not-in-icsp")
+	assert.False(t, ok)
+	_, ok = s.machineState("missing")
+	assert.False(t, ok)
+}
+
+// TestReadSnapshot - snapshots are shared for snapshotTTL
+func TestReadSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ov-icsp.json")
+
+	assert.Nil(t, readSnapshot(path))
+	data, _ := json.Marshal(statusSnapshot{Fetched: time.Now()})
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+	assert.NotNil(t, readSnapshot(path))
+
+	data, _ = json.Marshal(statusSnapshot{Fetched: time.Now().Add(-2 * snapshotTTL)})
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+	assert.Nil(t, readSnapshot(path))
+}
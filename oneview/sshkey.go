@@ -0,0 +1,285 @@
+package oneview
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+	"golang.org/x/crypto/ed25519"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ssh key types that can be generated for a machine
+const (
+	sshKeyTypeRSA     = "rsa"
+	sshKeyTypeED25519 = "ed25519"
+	sshKeyTypeECDSA   = "ecdsa"
+
+	// sshKeyAgent - value of --oneview-ssh-key that selects an ssh-agent identity,
+	// optionally followed by :<comment or SHA256 fingerprint>
+	sshKeyAgent = "agent"
+)
+
+// Error messages
+var (
+	ErrDriverInvalidSSHKeyType = errors.New("Invalid option --oneview-ssh-key-type, supported types are rsa, ed25519 and ecdsa")
+	ErrDriverMissingSSHAgent   = errors.New("Option --oneview-ssh-key requests an ssh-agent identity but SSH_AUTH_SOCK is not set")
+)
+
+// isValidSSHKeyType - check the key type is one we know how to generate
+func isValidSSHKeyType(keyType string) bool {
+	switch keyType {
+	case sshKeyTypeRSA, sshKeyTypeED25519, sshKeyTypeECDSA:
+		return true
+	}
+	return false
+}
+
+// sshKeyFileName - file name of a generated key of keyType in the machine
+// folder, named the way ssh-keygen names them
+func sshKeyFileName(keyType string) string {
+	if keyType == "" {
+		keyType = sshKeyTypeRSA
+	}
+	return "id_" + keyType
+}
+
+// usesSSHAgent - true when the machine identity is served by ssh-agent
+func (d *Driver) usesSSHAgent() bool {
+	return d.SSHKey == sshKeyAgent || strings.HasPrefix(d.SSHKey, sshKeyAgent+":")
+}
+
+// ownsSSHKey - true when the key files were generated by the driver and
+// can be removed with the machine
+func (d *Driver) ownsSSHKey() bool {
+	return d.SSHKey == ""
+}
+
+// GetSSHKeyPath - get the private key docker-machine uses to connect, an empty
+// path tells docker-machine to use the identities offered by ssh-agent
+func (d *Driver) GetSSHKeyPath() string {
+	if d.usesSSHAgent() {
+		return ""
+	}
+	return d.BaseDriver.GetSSHKeyPath()
+}
+
+// setSSHKeyConfig - apply the --oneview-ssh-key and --oneview-ssh-key-type options
+func (d *Driver) setSSHKeyConfig(key string, keyType string) error {
+	d.SSHKey = key
+	d.SSHKeyType = strings.ToLower(keyType)
+	if d.SSHKeyType == "" {
+		d.SSHKeyType = sshKeyTypeRSA
+	}
+	if !isValidSSHKeyType(d.SSHKeyType) {
+		return ErrDriverInvalidSSHKeyType
+	}
+	if d.SSHKey == "" || d.usesSSHAgent() {
+		return nil
+	}
+	keyPath, err := filepath.Abs(d.SSHKey)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		return fmt.Errorf("Unable to use ssh key from --oneview-ssh-key, %s", err)
+	}
+	d.SSHKey = keyPath
+	d.SSHKeyPath = keyPath
+	return nil
+}
+
+// generateSSHKey - write a new private and public key of keyType to path
+func generateSSHKey(path string, keyType string) error {
+	var (
+		privPEM *pem.Block
+		pub     gossh.PublicKey
+	)
+	switch keyType {
+	case sshKeyTypeRSA:
+		return ssh.GenerateSSHKey(path)
+	case sshKeyTypeECDSA:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		privPEM = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		if pub, err = gossh.NewPublicKey(&priv.PublicKey); err != nil {
+			return err
+		}
+	case sshKeyTypeED25519:
+		edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		der, err := marshalED25519PrivateKey(edPub, edPriv)
+		if err != nil {
+			return err
+		}
+		privPEM = &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: der}
+		if pub, err = gossh.NewPublicKey(edPub); err != nil {
+			return err
+		}
+	default:
+		return ErrDriverInvalidSSHKeyType
+	}
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(privPEM), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".pub", gossh.MarshalAuthorizedKey(pub), 0600)
+}
+
+// marshalED25519PrivateKey - encode an unencrypted openssh-key-v1 private key,
+// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+func marshalED25519PrivateKey(pub ed25519.PublicKey, priv ed25519.PrivateKey) ([]byte, error) {
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	pubKey := gossh.Marshal(struct {
+		KeyType string
+		Pub     []byte
+	}{gossh.KeyAlgoED25519, pub})
+
+	pk := struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{checkInt, checkInt, gossh.KeyAlgoED25519, pub, priv, "", nil}
+	// the private block is padded to the cipher block size, 8 for "none"
+	for i := 0; (len(gossh.Marshal(pk)))%8 != 0; i++ {
+		pk.Pad = append(pk.Pad, byte(i+1))
+	}
+
+	w := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, pubKey, gossh.Marshal(pk)}
+
+	return append([]byte("openssh-key-v1\x00"), gossh.Marshal(w)...), nil
+}
+
+// readPublicKey - read the public key that belongs to the private key at path,
+// deriving it from the private key when there is no .pub file next to it
+func readPublicKey(path string) (string, error) {
+	if publicKey, err := ioutil.ReadFile(path + ".pub"); err == nil {
+		return string(publicKey), nil
+	}
+	privateKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	signer, err := gossh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("Unable to read public key for %s, provide %s.pub : %s", path, path, err)
+	}
+	return string(gossh.MarshalAuthorizedKey(signer.PublicKey())), nil
+}
+
+// sshKeyFingerprint - SHA256 fingerprint as printed by ssh-keygen -l
+func sshKeyFingerprint(key gossh.PublicKey) string {
+	sum := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// getAgentSigner - find the ssh-agent identity selected with --oneview-ssh-key
+func (d *Driver) getAgentSigner() (gossh.Signer, error) {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return nil, ErrDriverMissingSSHAgent
+	}
+	return agentSigner(strings.TrimPrefix(strings.TrimPrefix(d.SSHKey, sshKeyAgent), ":"))
+}
+
+// sshAgent - the connection to ssh-agent and the identities picked from it,
+// shared by every signer of the process so the socket is opened once
+var sshAgent struct {
+	sync.Mutex
+	conn    net.Conn
+	signers map[string]gossh.Signer
+}
+
+// agentSigner - the ssh-agent identity with selector as comment or
+// fingerprint, the first identity when selector is empty
+func agentSigner(selector string) (gossh.Signer, error) {
+	sshAgent.Lock()
+	defer sshAgent.Unlock()
+	if s, ok := sshAgent.signers[selector]; ok {
+		return s, nil
+	}
+	if sshAgent.conn == nil {
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to connect to ssh-agent : %s", err)
+		}
+		sshAgent.conn, sshAgent.signers = conn, make(map[string]gossh.Signer)
+	}
+	client := agent.NewClient(sshAgent.conn)
+
+	keys, err := client.List()
+	if err != nil {
+		closeAgent()
+		return nil, err
+	}
+	var selected *agent.Key
+	for _, k := range keys {
+		if selector == "" || selector == k.Comment || selector == sshKeyFingerprint(k) {
+			selected = k
+			break
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("Unable to find identity %q in ssh-agent", selector)
+	}
+
+	signers, err := client.Signers()
+	if err != nil {
+		closeAgent()
+		return nil, err
+	}
+	for _, s := range signers {
+		if string(s.PublicKey().Marshal()) == string(selected.Marshal()) {
+			log.Debugf("using ssh-agent identity %s %s", selected.Comment, sshKeyFingerprint(selected))
+			sshAgent.signers[selector] = s
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("Unable to sign with identity %q from ssh-agent", selector)
+}
+
+// closeAgent - drop a broken ssh-agent connection, the next signer opens a
+// new one, the caller holds the lock
+func closeAgent() {
+	sshAgent.conn.Close()
+	sshAgent.conn, sshAgent.signers = nil, nil
+}
@@ -0,0 +1,78 @@
+package oneview
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// TestGenerateSSHKey - generated keys should be readable by the ssh client
+func TestGenerateSSHKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-sshkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, keyType := range []string{sshKeyTypeRSA, sshKeyTypeED25519, sshKeyTypeECDSA} {
+		path := filepath.Join(dir, "id_"+keyType)
+		err = generateSSHKey(path, keyType)
+		assert.NoError(t, err, "generateSSHKey threw error for %s -> %s\n", keyType, err)
+
+		privateKey, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		signer, err := gossh.ParsePrivateKey(privateKey)
+		assert.NoError(t, err, "ParsePrivateKey threw error for %s -> %s\n", keyType, err)
+
+		publicKey, err := readPublicKey(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(gossh.MarshalAuthorizedKey(signer.PublicKey())), publicKey)
+	}
+	assert.Equal(t, ErrDriverInvalidSSHKeyType, generateSSHKey(filepath.Join(dir, "id_dsa"), "dsa"))
+}
+
+// TestUserSuppliedSSHKey - keys from --oneview-ssh-key are used but never removed
+func TestUserSuppliedSSHKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-sshkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "id_ed25519")
+	assert.NoError(t, generateSSHKey(path, sshKeyTypeED25519))
+	// the public key is derived when there is no .pub file
+	assert.NoError(t, os.Remove(path+".pub"))
+
+	driver := Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}}
+	assert.NoError(t, driver.setSSHKeyConfig(path, ""))
+	assert.Equal(t, path, driver.GetSSHKeyPath())
+	assert.NoError(t, driver.createKeyPair())
+	assert.Contains(t, driver.SSHPublicKey, gossh.KeyAlgoED25519)
+	assert.NoError(t, driver.deleteKeyPair())
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "user supplied key should not be removed")
+
+	assert.NoError(t, driver.setSSHKeyConfig("agent:work", ""))
+	assert.Equal(t, "", driver.GetSSHKeyPath())
+	assert.Equal(t, ErrDriverInvalidSSHKeyType, driver.setSSHKeyConfig("", "dsa"))
+}
+
+// TestGeneratedSSHKeyName - generated keys are named after their type
+func TestGeneratedSSHKeyName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-sshkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "test"), 0700))
+
+	for _, keyType := range []string{sshKeyTypeRSA, sshKeyTypeED25519, sshKeyTypeECDSA} {
+		driver := Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test", StorePath: dir}}
+		assert.NoError(t, driver.setSSHKeyConfig("", keyType))
+		assert.NoError(t, driver.createKeyPair())
+		assert.Equal(t, filepath.Join(dir, "machines", "test", "id_"+keyType), driver.GetSSHKeyPath())
+		_, err := os.Stat(driver.GetSSHKeyPath() + ".pub")
+		assert.NoError(t, err)
+		assert.NoError(t, driver.deleteKeyPair())
+	}
+}
@@ -0,0 +1,150 @@
+package oneview
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// ErrDriverSSHKeyNotOwned - rotation is refused for keys we did not generate
+var ErrDriverSSHKeyNotOwned = errors.New("Only ssh keys generated by the driver can be rotated, rotate keys given with --oneview-ssh-key outside of docker-machine")
+
+// RotateSSHKey - replace the machine ssh key with a newly generated one.
+// The current key stays authorized on the blade until a login with the new
+// key has succeeded and the new key files are in place, so a failed or
+// interrupted rotation never locks us out.
+func (d *Driver) RotateSSHKey() error {
+	log.Debug("RotateSSHKey...")
+	if !d.ownsSSHKey() {
+		return ErrDriverSSHKeyNotOwned
+	}
+	if d.IPAddress == "" {
+		ip, err := d.GetIP()
+		if err != nil {
+			return err
+		}
+		d.IPAddress = ip
+	}
+
+	keyPath := d.GetSSHKeyPath()
+	newKeyPath := keyPath + ".rotate"
+	authorizedKeys := fmt.Sprintf("/home/%s/.ssh/authorized_keys", d.GetSSHUsername())
+
+	// machines created before --oneview-ssh-key-type have rsa keys
+	if d.SSHKeyType == "" {
+		d.SSHKeyType = sshKeyTypeRSA
+	}
+	log.Infof("Generating new SSH keys for %s...", d.MachineName)
+	if err := generateSSHKey(newKeyPath, d.SSHKeyType); err != nil {
+		return err
+	}
+	newPublicKey, err := readPublicKey(newKeyPath)
+	if err != nil {
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+
+	// authorize the new key next to the current one
+	sshClient, err := d.getLocalSSHClient()
+	if err != nil {
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+	if out, err := sshClient.Output(fmt.Sprintf(
+		"printf '\\n%%s' '%s' | tee -a %s",
+		newPublicKey,
+		authorizedKeys,
+	)); err != nil {
+		log.Error(out)
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+
+	// confirm the new key works, only the key is offered so a password
+	// login can not hide a broken key
+	host, port, err := d.sshEndpoint(d.IPAddress)
+	if err != nil {
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+	newClient, err := ssh.NewNativeClient(d.GetSSHUsername(), host, port, &ssh.Auth{
+		Keys: []string{newKeyPath},
+	})
+	if err != nil {
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+	if out, err := newClient.Output("true"); err != nil {
+		log.Error(out)
+		removeKeyFiles(newKeyPath)
+		return fmt.Errorf("Unable to login with the new ssh key, keeping the current key : %s", err)
+	}
+
+	// use the new key locally before the old one is revoked, the old key is
+	// kept next to it until the blade no longer accepts it
+	oldPublicKey, err := readPublicKey(keyPath)
+	if err != nil {
+		removeKeyFiles(newKeyPath)
+		return err
+	}
+	oldKeyPath := keyPath + ".old"
+	if err := swapKeyFiles(keyPath, newKeyPath, oldKeyPath); err != nil {
+		removeKeyFiles(newKeyPath)
+		return fmt.Errorf("Unable to replace %s with the new ssh key, keeping the current key : %s", keyPath, err)
+	}
+	d.SSHPublicKey = newPublicKey
+
+	// drop only the old key line from the blade, the other authorized keys
+	// are kept, written to a temporary file first so authorized_keys is
+	// never left half written
+	if out, err := newClient.Output(fmt.Sprintf(
+		"umask 077 && grep -v -F '%s' %s > %s.rotate && mv %s.rotate %s",
+		strings.TrimSpace(oldPublicKey),
+		authorizedKeys,
+		authorizedKeys,
+		authorizedKeys,
+		authorizedKeys,
+	)); err != nil {
+		log.Error(out)
+		return fmt.Errorf("New ssh key is in use but the old key %s is still authorized on %s : %s", oldKeyPath, d.MachineName, err)
+	}
+	removeKeyFiles(oldKeyPath)
+	log.Infof("%s, Rotated ssh key for %s.", d.DriverName(), d.MachineName)
+	return nil
+}
+
+// swapKeyFiles - move the key pair at path to old and the pair at next to
+// path, putting back what was moved when a rename fails so path and path.pub
+// always belong together
+func swapKeyFiles(path string, next string, old string) error {
+	moves := [][2]string{
+		{path, old},
+		{path + ".pub", old + ".pub"},
+		{next, path},
+		{next + ".pub", path + ".pub"},
+	}
+	for i, m := range moves {
+		if err := os.Rename(m[0], m[1]); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if err := os.Rename(moves[j][1], moves[j][0]); err != nil {
+					log.Warnf("Unable to move %s back to %s : %s", moves[j][1], moves[j][0], err)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// removeKeyFiles - best effort cleanup of a key pair we no longer need
+func removeKeyFiles(path string) {
+	for _, f := range []string{path, path + ".pub"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Unable to remove %s : %s", f, err)
+		}
+	}
+}
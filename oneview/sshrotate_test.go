@@ -0,0 +1,183 @@
+package oneview
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// startBlade - ssh server on localhost standing in for a blade, keys are
+// checked against authorized_keys under home and commands run with sh, the
+// /home/docker paths they name pointing to home
+func startBlade(t *testing.T, home string) net.Listener {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKeys := filepath.Join(home, ".ssh", "authorized_keys")
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(conn gossh.ConnMetadata, k gossh.PublicKey) (*gossh.Permissions, error) {
+			data, _ := ioutil.ReadFile(authorizedKeys)
+			for _, line := range strings.Split(string(data), "\n") {
+				if key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line)); err == nil && string(key.Marshal()) == string(k.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := gossh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go gossh.DiscardRequests(reqs)
+				for nc := range chans {
+					if nc.ChannelType() != "session" {
+						nc.Reject(gossh.UnknownChannelType, "only session")
+						continue
+					}
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						defer ch.Close()
+						for req := range chReqs {
+							if req.Type != "exec" {
+								req.Reply(false, nil)
+								continue
+							}
+							req.Reply(true, nil)
+							n := binary.BigEndian.Uint32(req.Payload)
+							command := strings.Replace(string(req.Payload[4:4+n]), "/home/docker", home, -1)
+							out, err := exec.Command("sh", "-c", command).CombinedOutput()
+							ch.Write(out)
+							status := make([]byte, 4)
+							if err != nil {
+								binary.BigEndian.PutUint32(status, 1)
+							}
+							ch.SendRequest("exit-status", false, status)
+							return
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+// TestRotateSSHKey - the new key replaces the old one on the blade and
+// locally, other authorized keys are kept
+func TestRotateSSHKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-sshrotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	home := filepath.Join(dir, "home")
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+
+	key := filepath.Join(dir, "id_rsa")
+	assert.NoError(t, generateSSHKey(key, sshKeyTypeRSA))
+	oldPublicKey, err := readPublicKey(key)
+	assert.NoError(t, err)
+	other := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGaW1SwR2TjnQpAB2XSdqumFwvbSNl0k/9KPIVz1Odqm ops"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(home, ".ssh", "authorized_keys"), []byte(other+"\n"+oldPublicKey), 0600))
+
+	l := startBlade(t, home)
+	defer l.Close()
+
+	// machines created before --oneview-ssh-key-type have no key type
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: "test",
+			IPAddress:   "127.0.0.1",
+			SSHKeyPath:  key,
+		},
+		SSHUser: "docker",
+		SSHPort: l.Addr().(*net.TCPAddr).Port,
+	}
+	assert.NoError(t, d.RotateSSHKey())
+	assert.Equal(t, sshKeyTypeRSA, d.SSHKeyType)
+
+	newPublicKey, err := readPublicKey(key)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldPublicKey, newPublicKey)
+	assert.Equal(t, newPublicKey, d.SSHPublicKey)
+	authorized, err := ioutil.ReadFile(filepath.Join(home, ".ssh", "authorized_keys"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(authorized), other)
+	assert.Contains(t, string(authorized), strings.TrimSpace(newPublicKey))
+	assert.NotContains(t, string(authorized), strings.TrimSpace(oldPublicKey))
+	for _, f := range []string{key + ".old", key + ".rotate"} {
+		_, err = os.Stat(f)
+		assert.True(t, os.IsNotExist(err), f)
+	}
+
+	d.SSHKey = key
+	assert.Equal(t, ErrDriverSSHKeyNotOwned, d.RotateSSHKey())
+}
+
+// TestSwapKeyFiles - the new pair replaces the current one, which is kept as
+// the old pair, and a failed swap leaves the current pair in place
+func TestSwapKeyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-sshkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	key := filepath.Join(dir, "id_rsa")
+	write := func(path string, data string) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+	}
+	read := func(path string) string {
+		data, _ := ioutil.ReadFile(path)
+		return string(data)
+	}
+
+	write(key, "current")
+	write(key+".pub", "current.pub")
+	write(key+".rotate", "new")
+	write(key+".rotate.pub", "new.pub")
+	assert.NoError(t, swapKeyFiles(key, key+".rotate", key+".old"))
+	assert.Equal(t, "new", read(key))
+	assert.Equal(t, "new.pub", read(key+".pub"))
+	assert.Equal(t, "current", read(key+".old"))
+	assert.Equal(t, "current.pub", read(key+".old.pub"))
+
+	// no public key for the next pair
+	write(key+".rotate", "next")
+	assert.Error(t, swapKeyFiles(key, key+".rotate", key+".older"))
+	assert.Equal(t, "new", read(key))
+	assert.Equal(t, "new.pub", read(key+".pub"))
+	assert.Equal(t, "next", read(key+".rotate"))
+	_, err = os.Stat(key + ".older")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,78 @@
+package oneview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// DefaultStorePath - docker-machine store used when MACHINE_STORAGE_PATH is not set
+func DefaultStorePath() string {
+	if path := os.Getenv("MACHINE_STORAGE_PATH"); path != "" {
+		return path
+	}
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker", "machine")
+}
+
+// machineConfigPath - config.json that docker-machine keeps for a machine
+func machineConfigPath(storePath string, machineName string) string {
+	return filepath.Join(storePath, "machines", machineName, "config.json")
+}
+
+// LoadDriver - read the driver of an existing machine from the docker-machine
+// store, used by operations that docker-machine has no command for
+func LoadDriver(storePath string, machineName string) (*Driver, error) {
+	var config map[string]json.RawMessage
+
+	data, err := ioutil.ReadFile(machineConfigPath(storePath, machineName))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	var driverName string
+	if err := json.Unmarshal(config["DriverName"], &driverName); err != nil || driverName != "oneview" {
+		return nil, fmt.Errorf("Machine %s is not managed by the oneview driver", machineName)
+	}
+
+	d := &Driver{BaseDriver: &drivers.BaseDriver{}}
+	if err := json.Unmarshal(config["Driver"], d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SaveDriver - write the driver settings back into the machine config,
+// leaving the rest of the docker-machine host settings untouched
+func SaveDriver(d *Driver) error {
+	var config map[string]json.RawMessage
+
+	configPath := machineConfigPath(d.StorePath, d.MachineName)
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if config["Driver"], err = json.Marshal(d); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(config, "", "    "); err != nil {
+		return err
+	}
+
+	// replace the file in one step so docker-machine never sees a partial config
+	tmpPath := configPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, configPath)
+}
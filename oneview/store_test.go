@@ -0,0 +1,37 @@
+package oneview
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadSaveDriver - driver settings round trip through the machine config
+func TestLoadSaveDriver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oneview-store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "test"), 0700))
+	config := `{"ConfigVersion": 3, "DriverName": "oneview", "Name": "test",
+		"Driver": {"MachineName": "test", "StorePath": "` + dir + `", "SSHUser": "docker", "SSHKeyType": "ed25519"}}`
+	assert.NoError(t, ioutil.WriteFile(machineConfigPath(dir, "test"), []byte(config), 0600))
+
+	d, err := LoadDriver(dir, "test")
+	assert.NoError(t, err, "LoadDriver threw error -> %s\n", err)
+	assert.Equal(t, "docker", d.SSHUser)
+	assert.Equal(t, sshKeyTypeED25519, d.SSHKeyType)
+
+	d.SSHPublicKey = "ssh-ed25519 AAAA"
+	assert.NoError(t, SaveDriver(d))
+	d, err = LoadDriver(dir, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAA", d.SSHPublicKey)
+
+	data, err := ioutil.ReadFile(machineConfigPath(dir, "test"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"ConfigVersion": 3`)
+}
@@ -0,0 +1,49 @@
+package oneview
+
+import (
+	"github.com/Sheetal-R/oneview-golang/ov"
+)
+
+// powerOn - power on the blade, the library waits for the power task.  A
+// task asking to retry is sent again, the library checks the power state
+// before changing it.
+func (d *Driver) powerOn() error {
+	hw := d.Hardware
+	hw.Client = d.ClientOV
+	return d.retry("power on", true, func() error {
+		return d.runPhase("power on", d.libraryCall(applianceOV, "PUT", hw.URI.String()+"/powerState", hw.PowerOn))
+	})
+}
+
+// powerOff - power off the blade, the library waits for the power task
+func (d *Driver) powerOff() error {
+	hw := d.Hardware
+	hw.Client = d.ClientOV
+	return d.retry("power off", true, func() error {
+		return d.runPhase("power off", d.libraryCall(applianceOV, "PUT", hw.URI.String()+"/powerState", hw.PowerOff))
+	})
+}
+
+// deleteProfile - delete the server profile of the machine and wait for the
+// task
+func (d *Driver) deleteProfile() error {
+	var t *ov.Task
+	err := d.libraryCall(applianceOV, "DELETE", d.Profile.URI.String(), func() (err error) {
+		t, err = d.ClientOV.SubmitDeleteProfile(d.Profile)
+		return err
+	})()
+	if err != nil {
+		return err
+	}
+	return d.waitTask("server profile deletion", t)
+}
+
+// createProfile - assign a server profile named after the machine, made from
+// the server template, to an available blade.  The library picks the blade
+// and waits for the task.  Not retried, the profile may exist when a later
+// request of the call fails.
+func (d *Driver) createProfile() error {
+	return d.runPhase("server profile assignment", d.libraryCall(applianceOV, "POST", "/rest/server-profiles", func() error {
+		return d.ClientOV.CreateMachine(d.MachineName, d.ServerTemplate)
+	}))
+}
@@ -0,0 +1,147 @@
+package oneview
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ErrPhaseTimeout - a phase of an operation did not finish in time, the
+// resources it worked on are left for the create rollback to handle
+type ErrPhaseTimeout struct {
+	Phase   string
+	Timeout time.Duration
+}
+
+// Error - describe the phase that timed out
+func (e ErrPhaseTimeout) Error() string {
+	return fmt.Sprintf("Timed out after %s waiting for %s", e.Timeout, e.Phase)
+}
+
+// parseDuration - parse a duration option, a plain number is taken as seconds
+func parseDuration(option string, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("Invalid option --%s %q, use a duration such as 90m or 30s", option, value)
+	}
+	return duration, nil
+}
+
+// taskTimeout - longest wait for one OneView task or ICSP job
+func (d *Driver) taskTimeout() time.Duration {
+	if d.TaskTimeout <= 0 {
+		return defaultTaskTimeout
+	}
+	return d.TaskTimeout
+}
+
+// pollInterval - time between two polls of a running task
+func (d *Driver) pollInterval() time.Duration {
+	if d.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return d.PollInterval
+}
+
+// startDeadline - bound every following phase by an overall timeout
+func (d *Driver) startDeadline(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultCreateTimeout
+	}
+	d.deadline = time.Now().Add(timeout)
+}
+
+// clearDeadline - phases are only bound by the task timeout again
+func (d *Driver) clearDeadline() {
+	d.deadline = time.Time{}
+}
+
+// phaseTimeout - time left for the next phase
+func (d *Driver) phaseTimeout() time.Duration {
+	timeout := d.taskTimeout()
+	if !d.deadline.IsZero() {
+		if left := d.deadline.Sub(time.Now()); left < timeout {
+			return left
+		}
+	}
+	return timeout
+}
+
+// runPhase - run a library call that waits on the appliance by itself and
+// give up waiting for it once the phase times out or the driver is
+// interrupted.  The call keeps running, waitPhases waits for it.
+func (d *Driver) runPhase(phase string, fn func() error) error {
+	timeout := d.phaseTimeout()
+	if timeout <= 0 {
+		return ErrPhaseTimeout{Phase: phase, Timeout: 0}
+	}
+	log.Debugf("waiting up to %s for %s", timeout, phase)
+
+	d.phases.Add(1)
+	done := make(chan error, 1)
+	go func() {
+		defer d.phases.Done()
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return ErrPhaseTimeout{Phase: phase, Timeout: timeout}
+	case <-d.interrupted():
+		return ErrDriverInterrupted
+	}
+}
+
+// waitPhases - wait, within the phase timeout, for the library calls
+// runPhase gave up on, the cleanup must not race them on the appliances.
+// False when they still run.
+func (d *Driver) waitPhases() bool {
+	done := make(chan struct{})
+	go func() {
+		d.phases.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	default:
+	}
+	log.Infof("Waiting for the running appliance call of %s to return before cleaning up...", d.MachineName)
+	select {
+	case <-done:
+		return true
+	case <-time.After(d.phaseTimeout()):
+		return false
+	}
+}
+
+// setTaskWait - poll the task every poll interval, for the phase timeout.
+// The library sleeps WaitTime seconds between polls, Timeout times.
+func (d *Driver) setTaskWait(t *ov.Task) {
+	interval := d.pollInterval()
+	t.WaitTime = interval / time.Second
+	if t.WaitTime < 1 {
+		t.WaitTime = 1
+	}
+	t.Timeout = int(d.phaseTimeout()/(t.WaitTime*time.Second)) + 1
+}
+
+// waitTask - wait for a OneView task returned by the library, polling at the
+// configured interval within the phase timeout
+func (d *Driver) waitTask(phase string, t *ov.Task) error {
+	if t == nil || t.URI.IsNil() {
+		return fmt.Errorf("No task returned for %s", phase)
+	}
+	d.setTaskWait(t)
+	return d.runPhase(phase, d.libraryCall(applianceOV, "GET", t.URI.String(), t.Wait))
+}
@@ -0,0 +1,86 @@
+package oneview
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sheetal-R/oneview-golang/ov"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseDuration - durations or plain seconds are accepted
+func TestParseDuration(t *testing.T) {
+	d, err := parseDuration("oneview-task-timeout", "", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+
+	d, err = parseDuration("oneview-task-timeout", "90m", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	d, err = parseDuration("oneview-poll-interval", "10", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, d)
+
+	_, err = parseDuration("oneview-poll-interval", "-1s", time.Minute)
+	assert.Error(t, err)
+	_, err = parseDuration("oneview-poll-interval", "soon", time.Minute)
+	assert.Error(t, err)
+}
+
+// TestRunPhase - library calls are bound by the task timeout and the create
+// deadline, and waited for before the cleanup
+func TestRunPhase(t *testing.T) {
+	d := Driver{BaseDriver: &drivers.BaseDriver{MachineName: "test"}, TaskTimeout: 20 * time.Millisecond}
+
+	err := d.runPhase("fast", func() error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+
+	returned := make(chan struct{})
+	err = d.runPhase("slow", func() error {
+		time.Sleep(100 * time.Millisecond)
+		close(returned)
+		return nil
+	})
+	assert.Equal(t, ErrPhaseTimeout{Phase: "slow", Timeout: 20 * time.Millisecond}, err)
+	// the cleanup waits for the abandoned call within its own timeout
+	d.TaskTimeout = time.Minute
+	assert.True(t, d.waitPhases())
+	select {
+	case <-returned:
+	default:
+		t.Error("waitPhases returned before the call")
+	}
+
+	d.startDeadline(20 * time.Millisecond)
+	assert.True(t, d.phaseTimeout() <= 20*time.Millisecond)
+	err = d.runPhase("slow", func() error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	assert.IsType(t, ErrPhaseTimeout{}, err)
+	assert.Contains(t, err.Error(), "waiting for slow")
+	assert.False(t, d.waitPhases())
+
+	d.clearDeadline()
+	assert.Equal(t, time.Minute, d.phaseTimeout())
+}
+
+// TestSetTaskWait - library tasks are polled at the poll interval for the
+// phase timeout
+func TestSetTaskWait(t *testing.T) {
+	d := Driver{TaskTimeout: time.Minute, PollInterval: 5 * time.Second}
+	var task ov.Task
+	d.setTaskWait(&task)
+	assert.Equal(t, time.Duration(5), task.WaitTime)
+	assert.Equal(t, 13, task.Timeout)
+
+	// the library waits whole seconds
+	d.PollInterval = 100 * time.Millisecond
+	d.startDeadline(10 * time.Second)
+	d.setTaskWait(&task)
+	assert.Equal(t, time.Duration(1), task.WaitTime)
+	assert.True(t, task.Timeout <= 11, "%d", task.Timeout)
+}
@@ -0,0 +1,121 @@
+package oneview
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiRequestTimeout - longest time one appliance request may take, tasks
+	// are polled with requests of their own
+	apiRequestTimeout = 2 * time.Minute
+	// apiDialTimeout - longest wait to connect to an appliance
+	apiDialTimeout = 30 * time.Second
+)
+
+// apiClients - http clients of the appliances, one per appliance so the
+// connections are reused by every request of the process
+type apiClients struct {
+	sync.Mutex
+	clients map[string]*http.Client
+}
+
+// apiTransport - every OneView and ICSP request goes through it, it waits
+// for the request limits of the appliance, keeps an in-flight slot until the
+// answer is read and records the request in the audit file
+type apiTransport struct {
+	d         *Driver
+	appliance string
+	endpoint  string
+	base      *http.Transport
+}
+
+// RoundTrip - send one request under the request limits.  The answer is read
+// before returning so the slot is free and the audit entry complete.
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := auditEntry{Appliance: t.appliance, Method: req.Method, Path: req.URL.RequestURI()}
+	if req.Body != nil {
+		payload, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		e.Body = redactBody(string(payload))
+	}
+
+	done, err := t.d.limiter(t.endpoint).acquire(true)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	var data []byte
+	if err == nil {
+		data, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	if err == nil {
+		e.Status = resp.StatusCode
+		e.TaskURI = responseTaskURI(resp, data)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			e.Error = redactBody(resp.Status + " " + string(data))
+		}
+	}
+	t.d.auditRequest(e, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CancelRequest - lets http.Client time out requests
+func (t *apiTransport) CancelRequest(req *http.Request) {
+	t.base.CancelRequest(req)
+}
+
+// responseTaskURI - the task an answer points to, asynchronous calls name it
+// in the Location header or in the body
+func responseTaskURI(resp *http.Response, data []byte) string {
+	if u, err := resp.Location(); err == nil && strings.HasPrefix(u.Path, "/rest/tasks/") {
+		return u.Path
+	}
+	return taskURI(data)
+}
+
+// apiClient - the http client for the appliance at endpoint
+func (d *Driver) apiClient(appliance string, endpoint string, sslVerify bool) *http.Client {
+	key := appliance + " " + endpoint
+	d.apiClients.Lock()
+	defer d.apiClients.Unlock()
+	if c, ok := d.apiClients.clients[key]; ok {
+		return c
+	}
+	c := &http.Client{
+		Transport: &apiTransport{
+			d:         d,
+			appliance: appliance,
+			endpoint:  endpoint,
+			base: &http.Transport{
+				Proxy:               d.apiProxy(endpoint),
+				Dial:                (&net.Dialer{Timeout: apiDialTimeout}).Dial,
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: !sslVerify},
+				TLSHandshakeTimeout: apiDialTimeout,
+			},
+		},
+		Timeout: apiRequestTimeout,
+	}
+	if d.apiClients.clients == nil {
+		d.apiClients.clients = make(map[string]*http.Client)
+	}
+	d.apiClients.clients[key] = c
+	return c
+}
@@ -6,6 +6,8 @@ DOCKER_PUBKEY=$2
 DOCKER_HOSTNAME=$3
 DOCKER_PROXY=$4
 PROXY_ENABLE=$5
+DOCKER_PORT=${6:-2376}
+NETWORK_SPEC=$7
 
 if [ -z "${DOCKER_PUBKEY}" ]; then
   echo "ERROR : this script requires a public key for docker user!"
@@ -65,6 +67,204 @@ echo "Completed updating permissions for sudoers on user ${DOCKER_USER}, $?"
 sed -i "s/localhost.localdomain/${DOCKER_HOSTNAME}/g" /etc/hostname
 echo "Completed hostname update : $(cat /etc/hostname), $?"
 
+# optionally bond interfaces, tag vlans and set a static address, NETWORK_SPEC is
+# a list of key=value settings from the driver, lists separated by commas :
+# bond=bond0 mode=active-backup slaves=<mac>,<mac> vlans=100,200
+# mac=<mac> address=10.0.0.20 prefix=24 gateway=10.0.0.1 dns=10.0.0.2,10.0.0.3 domain=example.com
+mac_interface() {
+  for dev in /sys/class/net/*; do
+    if [ "$(cat "${dev}/address" 2>/dev/null)" = "$(echo "$1" | tr 'A-F' 'a-f')" ]; then
+      basename "${dev}"
+      return 0
+    fi
+  done
+  return 1
+}
+# ifcfg_address <file> - the static address, or dhcp
+ifcfg_address() {
+  if [ -z "${STATIC_ADDRESS}" ]; then
+    echo "BOOTPROTO=dhcp" >> "$1"
+    return
+  fi
+  {
+    echo "BOOTPROTO=none"
+    echo "IPADDR=${STATIC_ADDRESS}"
+    echo "PREFIX=${STATIC_PREFIX}"
+    if [ -n "${STATIC_GATEWAY}" ]; then
+      echo "GATEWAY=${STATIC_GATEWAY}"
+    fi
+    n=1
+    for dns in ${STATIC_DNS}; do
+      echo "DNS${n}=${dns}"
+      n=$((n + 1))
+    done
+    if [ -n "${STATIC_DOMAIN}" ]; then
+      echo "DOMAIN=${STATIC_DOMAIN}"
+    fi
+  } >> "$1"
+}
+# networkd_address - [Network] settings of the static address, or dhcp
+networkd_address() {
+  if [ -z "${STATIC_ADDRESS}" ]; then
+    echo "DHCP=yes"
+    return
+  fi
+  echo "Address=${STATIC_ADDRESS}/${STATIC_PREFIX}"
+  if [ -n "${STATIC_GATEWAY}" ]; then
+    echo "Gateway=${STATIC_GATEWAY}"
+  fi
+  for dns in ${STATIC_DNS}; do
+    echo "DNS=${dns}"
+  done
+  if [ -n "${STATIC_DOMAIN}" ]; then
+    echo "Domains=${STATIC_DOMAIN}"
+  fi
+}
+if [ -n "${NETWORK_SPEC}" ] && [ "${NETWORK_SPEC}" != "none" ]; then
+  # the network settings are required, a machine left without its bond, vlans
+  # or static address must not look deployed
+  set -f
+  for setting in ${NETWORK_SPEC}; do
+    value=${setting#*=}
+    case "${setting%%=*}" in
+      bond) BOND=${value} ;;
+      mode) BOND_MODE=${value} ;;
+      slaves) BOND_MACS=$(echo "${value}" | tr ',' ' ') ;;
+      vlans) VLANS=$(echo "${value}" | tr ',' ' ') ;;
+      mac) STATIC_MAC=${value} ;;
+      address) STATIC_ADDRESS=${value} ;;
+      prefix) STATIC_PREFIX=${value} ;;
+      gateway) STATIC_GATEWAY=${value} ;;
+      dns) STATIC_DNS=$(echo "${value}" | tr ',' ' ') ;;
+      domain) STATIC_DOMAIN=${value} ;;
+      *) echo "ERROR : unable to read the network setting ${setting}"; exit 1 ;;
+    esac
+  done
+  set +f
+
+  if [ -d /etc/sysconfig/network-scripts ]; then
+    CFG=/etc/sysconfig/network-scripts
+    if [ -n "${BOND}" ]; then
+      cat > "${CFG}/ifcfg-${BOND}" << EOF
+DEVICE=${BOND}
+TYPE=Bond
+BONDING_MASTER=yes
+BONDING_OPTS="mode=${BOND_MODE} miimon=100"
+ONBOOT=yes
+EOF
+      if [ -n "${VLANS}" ]; then
+        echo "BOOTPROTO=none" >> "${CFG}/ifcfg-${BOND}"
+      else
+        ifcfg_address "${CFG}/ifcfg-${BOND}"
+      fi
+      for mac in ${BOND_MACS}; do
+        dev=$(mac_interface "${mac}") || { echo "ERROR : no interface with mac ${mac}"; exit 1; }
+        cat > "${CFG}/ifcfg-${dev}" << EOF
+DEVICE=${dev}
+HWADDR=${mac}
+MASTER=${BOND}
+SLAVE=yes
+BOOTPROTO=none
+ONBOOT=yes
+EOF
+      done
+      for vlan in ${VLANS}; do
+        cat > "${CFG}/ifcfg-${BOND}.${vlan}" << EOF
+DEVICE=${BOND}.${vlan}
+VLAN=yes
+BOOTPROTO=dhcp
+ONBOOT=yes
+EOF
+      done
+    elif [ -n "${STATIC_MAC}" ]; then
+      dev=$(mac_interface "${STATIC_MAC}") || { echo "ERROR : no interface with mac ${STATIC_MAC}"; exit 1; }
+      cat > "${CFG}/ifcfg-${dev}" << EOF
+DEVICE=${dev}
+HWADDR=${STATIC_MAC}
+ONBOOT=yes
+EOF
+      ifcfg_address "${CFG}/ifcfg-${dev}"
+    fi
+    systemctl restart network
+    echo "Completed ifcfg network configuration, $?"
+  else
+    CFG=/etc/systemd/network
+    mkdir -p "${CFG}"
+    if [ -n "${BOND}" ]; then
+      cat > "${CFG}/10-${BOND}.netdev" << EOF
+[NetDev]
+Name=${BOND}
+Kind=bond
+
+[Bond]
+Mode=${BOND_MODE}
+MIIMonitorSec=100ms
+EOF
+      for mac in ${BOND_MACS}; do
+        cat > "${CFG}/20-${BOND}-$(echo "${mac}" | tr -d ':').network" << EOF
+[Match]
+MACAddress=${mac}
+
+[Network]
+Bond=${BOND}
+EOF
+      done
+      {
+        echo "[Match]"
+        echo "Name=${BOND}"
+        echo ""
+        echo "[Network]"
+        if [ -n "${VLANS}" ]; then
+          echo "DHCP=no"
+          for vlan in ${VLANS}; do
+            echo "VLAN=${BOND}.${vlan}"
+          done
+        else
+          networkd_address
+        fi
+      } > "${CFG}/30-${BOND}.network"
+      for vlan in ${VLANS}; do
+        cat > "${CFG}/40-${BOND}.${vlan}.netdev" << EOF
+[NetDev]
+Name=${BOND}.${vlan}
+Kind=vlan
+
+[VLAN]
+Id=${vlan}
+EOF
+        cat > "${CFG}/40-${BOND}.${vlan}.network" << EOF
+[Match]
+Name=${BOND}.${vlan}
+
+[Network]
+DHCP=yes
+EOF
+      done
+    elif [ -n "${STATIC_MAC}" ]; then
+      {
+        echo "[Match]"
+        echo "MACAddress=${STATIC_MAC}"
+        echo ""
+        echo "[Network]"
+        networkd_address
+      } > "${CFG}/10-public.network"
+    fi
+    systemctl enable systemd-networkd
+    systemctl restart systemd-networkd
+    echo "Completed networkd configuration, $?"
+  fi
+fi
+
+# open the docker engine port, docker-machine configures the engine to listen on it
+if command -v firewall-cmd > /dev/null 2>&1 && firewall-cmd --state > /dev/null 2>&1; then
+  firewall-cmd --permanent --add-port="${DOCKER_PORT}/tcp"
+  firewall-cmd --reload
+  echo "Completed opening port ${DOCKER_PORT} with firewalld, $?"
+elif command -v iptables > /dev/null 2>&1; then
+  iptables -C INPUT -p tcp --dport "${DOCKER_PORT}" -j ACCEPT 2> /dev/null || iptables -I INPUT -p tcp --dport "${DOCKER_PORT}" -j ACCEPT
+  echo "Completed opening port ${DOCKER_PORT} with iptables, $?"
+fi
+
 echo "docker host provisioned by docker-machine oneview driver" >> /etc/motd
 echo "docker customizations complete"
 